@@ -1,36 +1,592 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
 	"os"
-  gx "golox/internal"
+	"path/filepath"
+	"strings"
+
+	gx "golox/internal"
+)
+
+// Exit codes follow sysexits.h, as in the reference jlox implementation.
+const (
+	exitUsage        = 64
+	exitStaticError  = 65
+	exitNoInput      = 66
+	exitRuntimeError = 70
 )
 
-func runFile(file_path string) {
-  source_code, err := os.ReadFile(file_path)
-  if err != nil {
-    panic(fmt.Sprintf("Error opening file: %v\n", err))
-  }
-  run(source_code)
+type options struct {
+	tokens     bool
+	ast        bool
+	astFormat  string
+	noRun      bool
+	tracePrint bool
+	color      bool
+	backend    string
+	division   gx.DivisionByZero
+}
+
+func runFile(file_path string, opts options) int {
+	source_code, err := os.ReadFile(file_path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+		return exitNoInput
+	}
+	return run(file_path, source_code, opts)
+}
+
+func run(file_path string, source_code []byte, opts options) int {
+	scanner := gx.NewScanner(source_code)
+	tokens, scanErrors := scanner.ScanTokens()
+	if opts.tokens {
+		for _, token := range tokens {
+			fmt.Println(token)
+		}
+	}
+
+	parser := gx.NewParser(tokens)
+	statements, parseErrors := parser.Parse()
+	if opts.ast {
+		if err := printAst(statements, opts.astFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "Error printing AST: %v\n", err)
+		}
+	}
+
+	printer := gx.DiagnosticPrinter{File: file_path, Source: source_code, Color: opts.color}
+	staticErrors := append(scanErrors, parseErrors...)
+	if len(staticErrors) > 0 {
+		fmt.Fprint(os.Stderr, printer.RenderErrors(staticErrors))
+		return exitStaticError
+	}
+
+	interpreter := gx.NewInterpreter()
+	interpreter.SetTracePrint(opts.tracePrint)
+	interpreter.SetDivisionByZero(opts.division)
+	resolver := gx.NewResolver(interpreter)
+	resolveErrors := resolver.Resolve(statements)
+	fmt.Fprint(os.Stderr, printer.RenderErrors(append(resolveErrors, resolver.Warnings...)))
+	if len(resolveErrors) > 0 {
+		return exitStaticError
+	}
+
+	if opts.backend == "vm" {
+		return runVM(statements, printer, opts)
+	}
+	if opts.noRun {
+		return 0
+	}
+	if err := interpreter.Interpret(statements); err != nil {
+		fmt.Fprint(os.Stderr, printer.RenderErrors([]error{err}))
+		return exitRuntimeError
+	}
+	return 0
+}
+
+// runVM compiles resolved statements to bytecode and runs them on the VM.
+func runVM(statements []gx.Stmt, printer gx.DiagnosticPrinter, opts options) int {
+	script, compileErrors := gx.Compile(statements)
+	if len(compileErrors) > 0 {
+		fmt.Fprint(os.Stderr, printer.RenderErrors(compileErrors))
+		return exitStaticError
+	}
+	if opts.noRun {
+		return 0
+	}
+	vm := gx.NewVM()
+	vm.SetTracePrint(opts.tracePrint)
+	vm.SetDivisionByZero(opts.division)
+	if err := vm.Interpret(script); err != nil {
+		fmt.Fprint(os.Stderr, printer.RenderErrors([]error{err}))
+		return exitRuntimeError
+	}
+	return 0
+}
+
+func printAst(statements []gx.Stmt, format string) error {
+	switch format {
+	case "sexpr":
+		fmt.Print(gx.AstPrinter{}.Print(statements))
+	case "json":
+		data, err := gx.AstJSON{}.Marshal(statements)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		return fmt.Errorf("unknown AST format %q", format)
+	}
+	return nil
+}
+
+// useColor decides whether diagnostics written to file get ANSI colors.
+// "auto" enables them for terminals unless NO_COLOR is set.
+func useColor(mode string, file *os.File) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// parseFlags parses the flags of a subcommand and returns its other
+// arguments. Flags may also follow them, as in
+// `golox compile file.gx -o file.gxc'; everything after `--' is an argument.
+func parseFlags(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		rest := flags.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...)
+		}
+		if len(rest) == 0 {
+			return positional
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// runCheck implements `golox check`: static checks only, with diagnostics
+// as text on stderr or as JSON on stdout.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("golox check", flag.ExitOnError)
+	format := flags.String("format", "text", "diagnostics format: text or json")
+	colorMode := flags.String("color", "auto", "color text diagnostics: auto, always or never")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: golox check [flags] script_path.gx")
+		flags.PrintDefaults()
+	}
+	args = parseFlags(flags, args)
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown --format %q, expected text or json\n", *format)
+		return exitUsage
+	}
+	if !validColorMode(*colorMode) {
+		fmt.Fprintf(os.Stderr, "Unknown --color %q, expected auto, always or never\n", *colorMode)
+		return exitUsage
+	}
+	if len(args) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	file_path := args[0]
+	source_code, err := os.ReadFile(file_path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+		return exitNoInput
+	}
+	errs := gx.Check(source_code)
+	printer := gx.DiagnosticPrinter{File: file_path, Source: source_code, Color: useColor(*colorMode, os.Stderr)}
+	if *format == "json" {
+		data, err := printer.JSON(errs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error printing diagnostics: %v\n", err)
+			return exitUsage
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Fprint(os.Stderr, printer.RenderErrors(errs))
+	}
+	if gx.HasErrors(errs) {
+		return exitStaticError
+	}
+	return 0
 }
 
-func run(source_code []byte) {
-  scanner := gx.NewScanner(source_code)
-  tokens := scanner.ScanTokens()
-  fmt.Printf("Tokens from scanner: %+v\n", tokens)
+// runFormat implements `golox fmt'. Formatted source goes to stdout unless
+// --write or --check is given; --check lists the files that would change and
+// fails if there are any.
+func runFormat(args []string) int {
+	flags := flag.NewFlagSet("golox fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "report files that are not formatted instead of printing them")
+	write := flags.Bool("write", false, "rewrite files in place")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: golox fmt [--check | --write] script_path.gx...")
+		flags.PrintDefaults()
+	}
+	args = parseFlags(flags, args)
 
-  parser := gx.NewParser(tokens)
-  parsedExpression := parser.Parse()
-  fmt.Printf("parsedExpression: %s\n", parsedExpression) 
+	if len(args) == 0 || (*check && *write) {
+		flags.Usage()
+		return exitUsage
+	}
 
-  interpreter := gx.NewInterpreter()
-  interpreter.Interpret(parsedExpression)
+	status := 0
+	for _, file_path := range args {
+		source_code, err := os.ReadFile(file_path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+			status = exitNoInput
+			continue
+		}
+		formatted, errs := gx.Format(source_code)
+		if len(errs) > 0 {
+			printer := gx.DiagnosticPrinter{File: file_path, Source: source_code, Color: useColor("auto", os.Stderr)}
+			fmt.Fprint(os.Stderr, printer.RenderErrors(errs))
+			status = exitStaticError
+			continue
+		}
+
+		switch {
+		case *check:
+			if !bytes.Equal(source_code, formatted) {
+				fmt.Println(file_path)
+				status = max(status, 1)
+			}
+		case *write:
+			if bytes.Equal(source_code, formatted) {
+				continue
+			}
+			if err := os.WriteFile(file_path, formatted, 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+				status = exitNoInput
+			}
+		default:
+			os.Stdout.Write(formatted)
+		}
+	}
+	return status
+}
+
+// defaultLintConfig is read by `golox lint' from the working directory when
+// no --config is given.
+const defaultLintConfig = ".goloxlint.json"
+
+// runLint implements `golox lint'. It exits with 1 when there are warnings
+// and with exitStaticError when a file does not parse.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("golox lint", flag.ExitOnError)
+	configPath := flags.String("config", "", "JSON file enabling or disabling rules (default "+defaultLintConfig+" if present)")
+	format := flags.String("format", "text", "diagnostics format: text or json")
+	listRules := flags.Bool("rules", false, "list the available rules and exit")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: golox lint [flags] script_path.gx...")
+		flags.PrintDefaults()
+	}
+	args = parseFlags(flags, args)
+
+	if *listRules {
+		for _, rule := range gx.LintRules {
+			fmt.Printf("%-24s %s\n", rule.Name, rule.Description)
+		}
+		return 0
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown --format %q, expected text or json\n", *format)
+		return exitUsage
+	}
+	if len(args) == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var config gx.LintConfig
+	if *configPath == "" {
+		if _, err := os.Stat(defaultLintConfig); err == nil {
+			*configPath = defaultLintConfig
+		}
+	}
+	if *configPath != "" {
+		var err error
+		if config, err = gx.LoadLintConfig(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading lint config: %v\n", err)
+			return exitUsage
+		}
+	}
+
+	status := 0
+	for _, file_path := range args {
+		source_code, err := os.ReadFile(file_path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+			status = exitNoInput
+			continue
+		}
+		errs := gx.Lint(source_code, config)
+		printer := gx.DiagnosticPrinter{File: file_path, Source: source_code, Color: useColor("auto", os.Stderr)}
+		if *format == "json" {
+			data, err := printer.JSON(errs)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error printing diagnostics: %v\n", err)
+				return exitUsage
+			}
+			fmt.Println(string(data))
+		} else {
+			fmt.Fprint(os.Stderr, printer.RenderErrors(errs))
+		}
+
+		for _, err := range errs {
+			if _, isWarning := err.(*gx.LintWarning); !isWarning {
+				status = max(status, exitStaticError)
+			}
+		}
+		if len(errs) > 0 {
+			status = max(status, 1)
+		}
+	}
+	return status
+}
+
+func validColorMode(mode string) bool {
+	return mode == "auto" || mode == "always" || mode == "never"
+}
+
+// compileSource runs the static checks and the Compiler over source_code,
+// rendering any errors to stderr. It returns exitStaticError when there are
+// errors and 0 otherwise.
+func compileSource(file_path string, source_code []byte) (*gx.CompiledFunction, int) {
+	printer := gx.DiagnosticPrinter{File: file_path, Source: source_code, Color: useColor("auto", os.Stderr)}
+	errs := gx.Check(source_code)
+	fmt.Fprint(os.Stderr, printer.RenderErrors(errs))
+	if gx.HasErrors(errs) {
+		return nil, exitStaticError
+	}
+	scanner := gx.NewScanner(source_code)
+	tokens, _ := scanner.ScanTokens()
+	parser := gx.NewParser(tokens)
+	statements, _ := parser.Parse()
+	script, compileErrors := gx.Compile(statements)
+	if len(compileErrors) > 0 {
+		fmt.Fprint(os.Stderr, printer.RenderErrors(compileErrors))
+		return nil, exitStaticError
+	}
+	return script, 0
+}
+
+// readCompiledFile loads a .gxc file, reporting problems on stderr.
+func readCompiledFile(file_path string) (gx.CompiledFile, int) {
+	file, err := os.Open(file_path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+		return gx.CompiledFile{}, exitNoInput
+	}
+	defer file.Close()
+	compiled, err := gx.ReadCompiledFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", file_path, err)
+		return gx.CompiledFile{}, exitStaticError
+	}
+	return compiled, 0
+}
+
+// runCompile implements `golox compile', which writes the bytecode of a
+// script to a .gxc file for `golox run'.
+func runCompile(args []string) int {
+	flags := flag.NewFlagSet("golox compile", flag.ExitOnError)
+	output := flags.String("o", "", "output file (default: the script path with a .gxc extension)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: golox compile script_path.gx [-o file.gxc]")
+		flags.PrintDefaults()
+	}
+	args = parseFlags(flags, args)
+	if len(args) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	file_path := args[0]
+	source_code, err := os.ReadFile(file_path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+		return exitNoInput
+	}
+	script, status := compileSource(file_path, source_code)
+	if status != 0 {
+		return status
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(file_path, filepath.Ext(file_path)) + ".gxc"
+	}
+	var buffer bytes.Buffer
+	gx.CompiledFile{SourceFile: file_path, Script: script}.WriteTo(&buffer)
+	if err := os.WriteFile(*output, buffer.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+		return exitNoInput
+	}
+	return 0
+}
+
+// runCompiled implements `golox run', which executes a .gxc file on the VM.
+func runCompiled(args []string) int {
+	flags := flag.NewFlagSet("golox run", flag.ExitOnError)
+	tracePrint := flags.Bool("trace-print", false, "prefix the output of print statements with `>>'")
+	divisionMode := flags.String("division-by-zero", "error", "what dividing by zero does: error or ieee (Infinity or NaN)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: golox run [flags] file.gxc")
+		flags.PrintDefaults()
+	}
+	args = parseFlags(flags, args)
+	if len(args) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	compiled, status := readCompiledFile(args[0])
+	if status != 0 {
+		return status
+	}
+	vm := gx.NewVM()
+	vm.SetTracePrint(*tracePrint)
+	division, ok := gx.ParseDivisionByZero(*divisionMode)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown --division-by-zero %q, expected error or ieee\n", *divisionMode)
+		return exitUsage
+	}
+	vm.SetDivisionByZero(division)
+	if err := vm.Interpret(compiled.Script); err != nil {
+		// The source may have changed since it was compiled, so errors are
+		// reported by position only.
+		printer := gx.DiagnosticPrinter{File: compiled.SourceFile, Color: useColor("auto", os.Stderr)}
+		fmt.Fprint(os.Stderr, printer.RenderErrors([]error{err}))
+		return exitRuntimeError
+	}
+	return 0
+}
+
+// runDisasm implements `golox disasm', which lists the bytecode of a script
+// or of a .gxc file.
+func runDisasm(args []string) int {
+	flags := flag.NewFlagSet("golox disasm", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: golox disasm script_path.gx | file.gxc")
+		flags.PrintDefaults()
+	}
+	args = parseFlags(flags, args)
+	if len(args) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	file_path := args[0]
+	if filepath.Ext(file_path) == ".gxc" {
+		compiled, status := readCompiledFile(file_path)
+		if status != 0 {
+			return status
+		}
+		fmt.Print(gx.Disassemble(compiled.Script, nil))
+		return 0
+	}
+
+	source_code, err := os.ReadFile(file_path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+		return exitNoInput
+	}
+	script, status := compileSource(file_path, source_code)
+	if status != 0 {
+		return status
+	}
+	fmt.Print(gx.Disassemble(script, source_code))
+	return 0
+}
+
+// runLanguageServer implements `golox lsp', a language server on stdio.
+func runLanguageServer() int {
+	if err := gx.NewLanguageServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func runPrompt(opts options) {
+	repl := gx.NewRepl(os.Stdout, os.Stderr)
+	repl.Interpreter.SetTracePrint(opts.tracePrint)
+	repl.Interpreter.SetDivisionByZero(opts.division)
+	repl.Color = opts.color
+	if home, err := os.UserHomeDir(); err == nil {
+		repl.HistoryFile = filepath.Join(home, ".golox_history")
+	}
+	if err := repl.LoadHistory(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+	}
+	repl.Run(os.Stdin)
 }
 
 func main() {
-  if len(os.Args) != 2 {
-    panic("Usage: golox <script_path.gx>")
-  } else {
-    runFile(os.Args[1])
-  }
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(runCheck(os.Args[2:]))
+		case "compile":
+			os.Exit(runCompile(os.Args[2:]))
+		case "disasm":
+			os.Exit(runDisasm(os.Args[2:]))
+		case "run":
+			os.Exit(runCompiled(os.Args[2:]))
+		case "fmt":
+			os.Exit(runFormat(os.Args[2:]))
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "lsp":
+			os.Exit(runLanguageServer())
+		}
+	}
+
+	var opts options
+	var colorMode string
+	flags := flag.NewFlagSet("golox", flag.ExitOnError)
+	flags.BoolVar(&opts.tokens, "tokens", false, "print the tokens produced by the scanner")
+	flags.BoolVar(&opts.ast, "ast", false, "print the parsed syntax tree")
+	flags.StringVar(&opts.astFormat, "ast-format", "sexpr", "syntax tree format for --ast: sexpr or json")
+	flags.BoolVar(&opts.noRun, "no-run", false, "stop after static checks instead of running the script")
+	flags.BoolVar(&opts.tracePrint, "trace-print", false, "prefix the output of print statements with `>>'")
+	divisionMode := flags.String("division-by-zero", "error", "what dividing by zero does: error or ieee (Infinity or NaN)")
+	flags.StringVar(&colorMode, "color", "auto", "color diagnostics: auto, always or never")
+	flags.StringVar(&opts.backend, "backend", "tree", "how to run scripts: tree (tree-walking interpreter) or vm (bytecode)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: golox [flags] [script_path.gx]")
+		fmt.Fprintln(flags.Output(), "       golox check [--format=text|json] script_path.gx")
+		fmt.Fprintln(flags.Output(), "       golox compile script_path.gx [-o file.gxc]")
+		fmt.Fprintln(flags.Output(), "       golox run [--trace-print] [--division-by-zero=error|ieee] file.gxc")
+		fmt.Fprintln(flags.Output(), "       golox disasm script_path.gx | file.gxc")
+		fmt.Fprintln(flags.Output(), "       golox fmt [--check | --write] script_path.gx...")
+		fmt.Fprintln(flags.Output(), "       golox lint [--config=file] [--format=text|json] script_path.gx...")
+		fmt.Fprintln(flags.Output(), "       golox lsp")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if opts.astFormat != "sexpr" && opts.astFormat != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown --ast-format %q, expected sexpr or json\n", opts.astFormat)
+		os.Exit(exitUsage)
+	}
+	if !validColorMode(colorMode) {
+		fmt.Fprintf(os.Stderr, "Unknown --color %q, expected auto, always or never\n", colorMode)
+		os.Exit(exitUsage)
+	}
+	if opts.backend != "tree" && opts.backend != "vm" {
+		fmt.Fprintf(os.Stderr, "Unknown --backend %q, expected tree or vm\n", opts.backend)
+		os.Exit(exitUsage)
+	}
+	var ok bool
+	if opts.division, ok = gx.ParseDivisionByZero(*divisionMode); !ok {
+		fmt.Fprintf(os.Stderr, "Unknown --division-by-zero %q, expected error or ieee\n", *divisionMode)
+		os.Exit(exitUsage)
+	}
+	opts.color = useColor(colorMode, os.Stderr)
+
+	switch flags.NArg() {
+	case 0:
+		runPrompt(opts)
+	case 1:
+		os.Exit(runFile(flags.Arg(0), opts))
+	default:
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
 }
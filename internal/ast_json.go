@@ -0,0 +1,237 @@
+package internal
+
+import "encoding/json"
+
+// AstJSON converts statements and expressions into plain maps that
+// encoding/json can marshal. Every node carries its kind under "node".
+type AstJSON struct{}
+
+func (j AstJSON) Marshal(statements []Stmt) ([]byte, error) {
+	return json.MarshalIndent(j.statements(statements), "", "  ")
+}
+
+func (j AstJSON) statements(statements []Stmt) []any {
+	nodes := make([]any, len(statements))
+	for n, stmt := range statements {
+		nodes[n] = j.node(stmt)
+	}
+	return nodes
+}
+
+func (j AstJSON) expressions(expressions []Expr) []any {
+	nodes := make([]any, len(expressions))
+	for n, expr := range expressions {
+		nodes[n] = j.node(expr)
+	}
+	return nodes
+}
+
+// node converts a sub-tree and records its source span. A missing sub-tree,
+// such as an absent else branch, becomes null.
+func (j AstJSON) node(node any) any {
+	var converted map[string]any
+	var span Span
+	switch node := node.(type) {
+	case Expr:
+		converted, span = node.Apply(j).(map[string]any), node.Span()
+	case Stmt:
+		converted, span = node.Apply(j).(map[string]any), node.Span()
+	default:
+		return nil
+	}
+	converted["span"] = j.span(span)
+	return converted
+}
+
+func (j AstJSON) span(span Span) map[string]any {
+	position := func(p Position) map[string]any {
+		return map[string]any{"line": p.Line, "column": p.Column, "offset": p.Offset}
+	}
+	return map[string]any{"start": position(span.Start), "end": position(span.End)}
+}
+
+func (j AstJSON) token(token Token) map[string]any {
+	return map[string]any{
+		"lexeme": token.Lexeme,
+		"line":   token.Line,
+		"column": token.Column,
+		"offset": token.Offset,
+		"length": token.Length,
+	}
+}
+
+// statements
+
+func (j AstJSON) VisitExpression(stmt Expression) any {
+	return map[string]any{"node": "Expression", "expression": j.node(stmt.Expr)}
+}
+
+func (j AstJSON) VisitPrint(stmt Print) any {
+	return map[string]any{"node": "Print", "expression": j.node(stmt.Expr)}
+}
+
+func (j AstJSON) VisitVarDeclare(stmt VarDeclare) any {
+	return map[string]any{
+		"node":        "VarDeclare",
+		"name":        j.token(stmt.Name),
+		"initializer": j.node(stmt.InitialExpr),
+		"doc":         stmt.Doc,
+	}
+}
+
+func (j AstJSON) VisitBlock(stmt Block) any {
+	return map[string]any{"node": "Block", "statements": j.statements(stmt.Statements)}
+}
+
+func (j AstJSON) VisitIfStmt(stmt IfStmt) any {
+	return map[string]any{
+		"node":      "If",
+		"condition": j.node(stmt.Condition),
+		"then":      j.node(stmt.ThenBranch),
+		"else":      j.node(stmt.ElseBranch),
+	}
+}
+
+func (j AstJSON) VisitWhileStmt(stmt WhileStmt) any {
+	return map[string]any{
+		"node":      "While",
+		"condition": j.node(stmt.Condition),
+		"body":      j.node(stmt.Body),
+	}
+}
+
+func (j AstJSON) VisitFunctionStmt(stmt FunctionStmt) any {
+	params := make([]any, len(stmt.Params))
+	for n, param := range stmt.Params {
+		params[n] = j.token(param)
+	}
+	return map[string]any{
+		"node":   "Function",
+		"name":   j.token(stmt.Name),
+		"params": params,
+		"body":   j.statements(stmt.Body),
+		"doc":    stmt.Doc,
+	}
+}
+
+func (j AstJSON) VisitReturnStmt(stmt ReturnStmt) any {
+	return map[string]any{
+		"node":    "Return",
+		"keyword": j.token(stmt.Keyword),
+		"value":   j.node(stmt.Value),
+	}
+}
+
+func (j AstJSON) VisitClassStmt(stmt ClassStmt) any {
+	methods := make([]any, len(stmt.Methods))
+	for n, method := range stmt.Methods {
+		methods[n] = j.node(method)
+	}
+	var superclass any
+	if stmt.Superclass != nil {
+		superclass = j.node(stmt.Superclass)
+	}
+	return map[string]any{
+		"node":       "Class",
+		"name":       j.token(stmt.Name),
+		"superclass": superclass,
+		"methods":    methods,
+	}
+}
+
+// expressions
+
+func (j AstJSON) VisitBinaryExpr(expr *Binary) any {
+	return map[string]any{
+		"node":     "Binary",
+		"operator": j.token(expr.Operator),
+		"left":     j.node(expr.Left),
+		"right":    j.node(expr.Right),
+	}
+}
+
+func (j AstJSON) VisitUnaryExpr(expr *Unary) any {
+	return map[string]any{
+		"node":     "Unary",
+		"operator": j.token(expr.Operator),
+		"right":    j.node(expr.Right),
+	}
+}
+
+func (j AstJSON) VisitLiteralExpr(expr *Literal) any {
+	return map[string]any{"node": "Literal", "value": expr.Value}
+}
+
+func (j AstJSON) VisitGroupingExpr(expr *Grouping) any {
+	return map[string]any{"node": "Grouping", "expression": j.node(expr.Inside)}
+}
+
+func (j AstJSON) VisitVariableExpr(expr *Variable) any {
+	return map[string]any{"node": "Variable", "name": j.token(expr.Name)}
+}
+
+func (j AstJSON) VisitAssignmentExpr(expr *Assignment) any {
+	return map[string]any{
+		"node":  "Assignment",
+		"name":  j.token(expr.Name),
+		"value": j.node(expr.Value),
+	}
+}
+
+func (j AstJSON) VisitCallExpr(expr *Call) any {
+	return map[string]any{
+		"node":      "Call",
+		"callee":    j.node(expr.Callee),
+		"arguments": j.expressions(expr.Arguments),
+	}
+}
+
+func (j AstJSON) VisitLogicalExpr(expr *Logic) any {
+	return map[string]any{
+		"node":     "Logical",
+		"operator": j.token(expr.Operator),
+		"left":     j.node(expr.Left),
+		"right":    j.node(expr.Right),
+	}
+}
+
+func (j AstJSON) VisitGetExpr(expr *Get) any {
+	return map[string]any{
+		"node":   "Get",
+		"object": j.node(expr.Object),
+		"name":   j.token(expr.Name),
+	}
+}
+
+func (j AstJSON) VisitSetExpr(expr *Set) any {
+	return map[string]any{
+		"node":   "Set",
+		"object": j.node(expr.Object),
+		"name":   j.token(expr.Name),
+		"value":  j.node(expr.Value),
+	}
+}
+
+func (j AstJSON) VisitThisExpr(expr *This) any {
+	return map[string]any{"node": "This", "keyword": j.token(expr.Keyword)}
+}
+
+func (j AstJSON) VisitSuperExpr(expr *Super) any {
+	return map[string]any{
+		"node":    "Super",
+		"keyword": j.token(expr.Keyword),
+		"method":  j.token(expr.Method),
+	}
+}
+
+func (j AstJSON) VisitInterpolationExpr(expr *Interpolation) any {
+	segments := make([]any, len(expr.Segments))
+	for n, segment := range expr.Segments {
+		segments[n] = segment.Literal
+	}
+	return map[string]any{
+		"node":        "Interpolation",
+		"segments":    segments,
+		"expressions": j.expressions(expr.Expressions),
+	}
+}
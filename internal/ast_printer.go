@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AstPrinter renders statements and expressions as Lisp-style
+// s-expressions, e.g. `(print (+ 1 (group 2)))'.
+type AstPrinter struct{}
+
+func (p AstPrinter) Print(statements []Stmt) string {
+	var sb strings.Builder
+	for _, stmt := range statements {
+		sb.WriteString(stmt.Apply(p).(string))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (p AstPrinter) parenthesize(name string, parts ...any) string {
+	var sb strings.Builder
+	sb.WriteString("(")
+	sb.WriteString(name)
+	for _, part := range parts {
+		sb.WriteString(" ")
+		switch part := part.(type) {
+		case Expr:
+			sb.WriteString(part.Apply(p).(string))
+		case Stmt:
+			sb.WriteString(part.Apply(p).(string))
+		case []Stmt:
+			for n, stmt := range part {
+				if n > 0 {
+					sb.WriteString(" ")
+				}
+				sb.WriteString(stmt.Apply(p).(string))
+			}
+		case Token:
+			sb.WriteString(part.Lexeme)
+		default:
+			sb.WriteString(fmt.Sprint(part))
+		}
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// statements
+
+func (p AstPrinter) VisitExpression(stmt Expression) any {
+	return p.parenthesize(";", stmt.Expr)
+}
+
+func (p AstPrinter) VisitPrint(stmt Print) any {
+	return p.parenthesize("print", stmt.Expr)
+}
+
+func (p AstPrinter) VisitVarDeclare(stmt VarDeclare) any {
+	if stmt.InitialExpr == nil {
+		return p.parenthesize("var", stmt.Name)
+	}
+	return p.parenthesize("var", stmt.Name, stmt.InitialExpr)
+}
+
+func (p AstPrinter) VisitBlock(stmt Block) any {
+	return p.parenthesize("block", stmt.Statements)
+}
+
+func (p AstPrinter) VisitIfStmt(stmt IfStmt) any {
+	if stmt.ElseBranch == nil {
+		return p.parenthesize("if", stmt.Condition, stmt.ThenBranch)
+	}
+	return p.parenthesize("if-else", stmt.Condition, stmt.ThenBranch, stmt.ElseBranch)
+}
+
+func (p AstPrinter) VisitWhileStmt(stmt WhileStmt) any {
+	return p.parenthesize("while", stmt.Condition, stmt.Body)
+}
+
+func (p AstPrinter) VisitFunctionStmt(stmt FunctionStmt) any {
+	params := make([]string, len(stmt.Params))
+	for n, param := range stmt.Params {
+		params[n] = param.Lexeme
+	}
+	return p.parenthesize("fun", stmt.Name, "("+strings.Join(params, " ")+")", stmt.Body)
+}
+
+func (p AstPrinter) VisitReturnStmt(stmt ReturnStmt) any {
+	if stmt.Value == nil {
+		return "(return)"
+	}
+	return p.parenthesize("return", stmt.Value)
+}
+
+func (p AstPrinter) VisitClassStmt(stmt ClassStmt) any {
+	parts := []any{stmt.Name}
+	if stmt.Superclass != nil {
+		parts = append(parts, "<", stmt.Superclass.Name)
+	}
+	for _, method := range stmt.Methods {
+		parts = append(parts, Stmt(method))
+	}
+	return p.parenthesize("class", parts...)
+}
+
+// expressions
+
+func (p AstPrinter) VisitBinaryExpr(expr *Binary) any {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
+}
+
+func (p AstPrinter) VisitUnaryExpr(expr *Unary) any {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Right)
+}
+
+func (p AstPrinter) VisitLiteralExpr(expr *Literal) any {
+	switch value := expr.Value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return fmt.Sprintf("%q", value)
+	default:
+		return LiteralValue(value).String()
+	}
+}
+
+func (p AstPrinter) VisitGroupingExpr(expr *Grouping) any {
+	return p.parenthesize("group", expr.Inside)
+}
+
+func (p AstPrinter) VisitVariableExpr(expr *Variable) any {
+	return expr.Name.Lexeme
+}
+
+func (p AstPrinter) VisitAssignmentExpr(expr *Assignment) any {
+	return p.parenthesize("=", expr.Name, expr.Value)
+}
+
+func (p AstPrinter) VisitCallExpr(expr *Call) any {
+	parts := []any{expr.Callee}
+	for _, arg := range expr.Arguments {
+		parts = append(parts, arg)
+	}
+	return p.parenthesize("call", parts...)
+}
+
+func (p AstPrinter) VisitLogicalExpr(expr *Logic) any {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
+}
+
+func (p AstPrinter) VisitGetExpr(expr *Get) any {
+	return p.parenthesize(".", expr.Object, expr.Name)
+}
+
+func (p AstPrinter) VisitSetExpr(expr *Set) any {
+	return p.parenthesize("=", p.parenthesize(".", expr.Object, expr.Name), expr.Value)
+}
+
+func (p AstPrinter) VisitThisExpr(expr *This) any {
+	return "this"
+}
+
+func (p AstPrinter) VisitSuperExpr(expr *Super) any {
+	return p.parenthesize("super", expr.Method)
+}
+
+// VisitInterpolationExpr prints "a ${b} c" as `(str "a " b " c")', leaving
+// out empty segments.
+func (p AstPrinter) VisitInterpolationExpr(expr *Interpolation) any {
+	var parts []any
+	for n, segment := range expr.Segments {
+		if text := segment.Literal.(string); text != "" {
+			parts = append(parts, fmt.Sprintf("%q", text))
+		}
+		if n < len(expr.Expressions) {
+			parts = append(parts, expr.Expressions[n])
+		}
+	}
+	return p.parenthesize("str", parts...)
+}
@@ -0,0 +1,163 @@
+package internal
+
+import "sort"
+
+// OpCode is one VM instruction. Operands follow it in Chunk.Code: constant,
+// global and property names are 16-bit indexes into Chunk.Constants, local
+// and upvalue slots and argument counts are single bytes, and jumps are
+// 16-bit distances.
+type OpCode byte
+
+const (
+	OpConstant     OpCode = iota // index16
+	OpNil                        //
+	OpTrue                       //
+	OpFalse                      //
+	OpPop                        //
+	OpGetLocal                   // slot
+	OpSetLocal                   // slot
+	OpGetGlobal                  // name16
+	OpDefineGlobal               // name16
+	OpSetGlobal                  // name16
+	OpGetUpvalue                 // slot
+	OpSetUpvalue                 // slot
+	OpGetProperty                // name16
+	OpSetProperty                // name16
+	OpGetSuper                   // name16
+	OpEqual                      //
+	OpNotEqual                   //
+	OpGreater                    //
+	OpGreaterEqual               //
+	OpLess                       //
+	OpLessEqual                  //
+	OpAdd                        //
+	OpSubtract                   //
+	OpMultiply                   //
+	OpDivide                     //
+	OpNot                        //
+	OpNegate                     //
+	OpPrint                      //
+	OpJump                       // offset16
+	OpJumpIfFalse                // offset16, leaves the condition on the stack
+	OpLoop                       // offset16, backwards
+	OpCall                       // argc
+	OpClosure                    // function16, then (isLocal, index) per upvalue
+	OpCloseUpvalue               //
+	OpReturn                     //
+	OpClass                      // name16
+	OpInherit                    //
+	OpMethod                     // name16
+	OpInterpolate                // count, joins that many values into a string
+	OpIntDivide                  //
+	OpModulo                     //
+)
+
+var opNames = [...]string{
+	OpConstant:     "OP_CONSTANT",
+	OpNil:          "OP_NIL",
+	OpTrue:         "OP_TRUE",
+	OpFalse:        "OP_FALSE",
+	OpPop:          "OP_POP",
+	OpGetLocal:     "OP_GET_LOCAL",
+	OpSetLocal:     "OP_SET_LOCAL",
+	OpGetGlobal:    "OP_GET_GLOBAL",
+	OpDefineGlobal: "OP_DEFINE_GLOBAL",
+	OpSetGlobal:    "OP_SET_GLOBAL",
+	OpGetUpvalue:   "OP_GET_UPVALUE",
+	OpSetUpvalue:   "OP_SET_UPVALUE",
+	OpGetProperty:  "OP_GET_PROPERTY",
+	OpSetProperty:  "OP_SET_PROPERTY",
+	OpGetSuper:     "OP_GET_SUPER",
+	OpEqual:        "OP_EQUAL",
+	OpNotEqual:     "OP_NOT_EQUAL",
+	OpGreater:      "OP_GREATER",
+	OpGreaterEqual: "OP_GREATER_EQUAL",
+	OpLess:         "OP_LESS",
+	OpLessEqual:    "OP_LESS_EQUAL",
+	OpAdd:          "OP_ADD",
+	OpSubtract:     "OP_SUBTRACT",
+	OpMultiply:     "OP_MULTIPLY",
+	OpDivide:       "OP_DIVIDE",
+	OpNot:          "OP_NOT",
+	OpNegate:       "OP_NEGATE",
+	OpPrint:        "OP_PRINT",
+	OpJump:         "OP_JUMP",
+	OpJumpIfFalse:  "OP_JUMP_IF_FALSE",
+	OpLoop:         "OP_LOOP",
+	OpCall:         "OP_CALL",
+	OpClosure:      "OP_CLOSURE",
+	OpCloseUpvalue: "OP_CLOSE_UPVALUE",
+	OpReturn:       "OP_RETURN",
+	OpClass:        "OP_CLASS",
+	OpInherit:      "OP_INHERIT",
+	OpMethod:       "OP_METHOD",
+	OpInterpolate:  "OP_INTERPOLATE",
+	OpIntDivide:    "OP_INT_DIVIDE",
+	OpModulo:       "OP_MODULO",
+}
+
+func (op OpCode) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return "OP_UNKNOWN"
+}
+
+// Chunk is the bytecode of one function. Constants holds the numbers,
+// strings and nested functions the code refers to. Lines maps code offsets
+// back to the source: each entry covers the code from its Offset up to the
+// next entry's.
+type Chunk struct {
+	Code      []byte
+	Constants []Value
+	Lines     []LineStart
+}
+
+// LineStart marks where the code compiled from Token begins.
+type LineStart struct {
+	Offset int
+	Token  Token
+}
+
+// CompiledFunction is a function body ready to run on the VM. The top-level
+// script is compiled as a function with an empty Name.
+type CompiledFunction struct {
+	Name         string
+	Arity        int
+	UpvalueCount int
+	Chunk        Chunk
+}
+
+func (f *CompiledFunction) String() string {
+	if f.Name == "" {
+		return "<script>"
+	}
+	return "<fn " + f.Name + ">"
+}
+
+func (c *Chunk) write(b byte, token Token) {
+	if len(c.Lines) == 0 || c.Lines[len(c.Lines)-1].Token != token {
+		c.Lines = append(c.Lines, LineStart{len(c.Code), token})
+	}
+	c.Code = append(c.Code, b)
+}
+
+func (c *Chunk) addConstant(value Value) int {
+	for n, constant := range c.Constants {
+		if !value.IsObject() && constant == value {
+			return n
+		}
+	}
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+// TokenAt returns the token that the instruction at offset was compiled
+// from.
+func (c *Chunk) TokenAt(offset int) Token {
+	n := sort.Search(len(c.Lines), func(n int) bool { return c.Lines[n].Offset > offset })
+	if n == 0 {
+		return Token{}
+	}
+	return c.Lines[n-1].Token
+}
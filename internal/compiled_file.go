@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// CompiledFileVersion is bumped whenever the bytecode or its encoding
+// changes, so stale .gxc files are rejected instead of misread.
+const CompiledFileVersion = 3
+
+var compiledFileMagic = []byte("GXC\x00")
+
+// CompiledFile is the content of a .gxc file: a compiled script and the
+// name of the source file it was compiled from, which runtime errors refer
+// to.
+//
+// On disk it is the magic "GXC\x00", the version as a uint16, the payload
+// length and its CRC-32 as uint32s, all big-endian, and then the payload.
+type CompiledFile struct {
+	SourceFile string
+	Script     *CompiledFunction
+}
+
+// constant tags in the payload
+const (
+	constantFloat byte = iota + 1
+	constantString
+	constantFunction
+	constantInt
+)
+
+func (f CompiledFile) WriteTo(w io.Writer) (int64, error) {
+	payload := appendString(nil, f.SourceFile)
+	payload = appendFunction(payload, f.Script)
+
+	header := append([]byte{}, compiledFileMagic...)
+	header = binary.BigEndian.AppendUint16(header, CompiledFileVersion)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(payload)))
+	header = binary.BigEndian.AppendUint32(header, crc32.ChecksumIEEE(payload))
+	n, err := w.Write(append(header, payload...))
+	return int64(n), err
+}
+
+// ReadCompiledFile decodes a .gxc file, checking its version and checksum.
+func ReadCompiledFile(r io.Reader) (CompiledFile, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return CompiledFile{}, err
+	}
+	if !bytes.HasPrefix(data, compiledFileMagic) || len(data) < 14 {
+		return CompiledFile{}, errors.New("not a compiled golox file")
+	}
+	if version := binary.BigEndian.Uint16(data[4:]); version != CompiledFileVersion {
+		return CompiledFile{}, fmt.Errorf("compiled file version %d is not supported, recompile it with this golox (version %d)", version, CompiledFileVersion)
+	}
+	length, checksum, payload := binary.BigEndian.Uint32(data[6:]), binary.BigEndian.Uint32(data[10:]), data[14:]
+	if int(length) != len(payload) || crc32.ChecksumIEEE(payload) != checksum {
+		return CompiledFile{}, errors.New("compiled file is corrupt: checksum mismatch")
+	}
+
+	d := &payloadDecoder{data: payload}
+	file := CompiledFile{SourceFile: d.string(), Script: d.function()}
+	if d.err == nil && len(d.data) > 0 {
+		d.err = errors.New("trailing data")
+	}
+	if d.err != nil {
+		return CompiledFile{}, fmt.Errorf("compiled file is corrupt: %w", d.err)
+	}
+	return file, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendInt(b []byte, n int) []byte {
+	return binary.AppendUvarint(b, uint64(n))
+}
+
+// appendFunction encodes function and, depth first, the functions among its
+// constants. Tokens are stored without their Literal, which only the
+// Parser needs.
+func appendFunction(b []byte, function *CompiledFunction) []byte {
+	b = appendString(b, function.Name)
+	b = appendInt(b, function.Arity)
+	b = appendInt(b, function.UpvalueCount)
+
+	chunk := &function.Chunk
+	b = appendInt(b, len(chunk.Code))
+	b = append(b, chunk.Code...)
+	b = appendInt(b, len(chunk.Constants))
+	for _, constant := range chunk.Constants {
+		function, isFunction := constant.AsObject().(*CompiledFunction)
+		switch {
+		case constant.IsInt():
+			b = append(b, constantInt)
+			b = binary.BigEndian.AppendUint64(b, uint64(constant.AsInt()))
+		case constant.IsFloat():
+			b = append(b, constantFloat)
+			b = binary.BigEndian.AppendUint64(b, math.Float64bits(constant.AsFloat()))
+		case constant.IsString():
+			b = append(b, constantString)
+			b = appendString(b, constant.AsString())
+		case isFunction:
+			b = append(b, constantFunction)
+			b = appendFunction(b, function)
+		default:
+			panic(fmt.Sprintf("cannot encode constant %v", constant))
+		}
+	}
+	b = appendInt(b, len(chunk.Lines))
+	for _, line := range chunk.Lines {
+		token := line.Token
+		b = appendInt(b, line.Offset)
+		b = appendInt(b, int(token.TokenType))
+		b = appendString(b, token.Lexeme)
+		for _, n := range []int{token.Line, token.Column, token.Offset, token.Length} {
+			b = appendInt(b, n)
+		}
+	}
+	return b
+}
+
+// payloadDecoder reads what appendFunction wrote. The first problem is kept
+// in err and every read after it returns zero values.
+type payloadDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *payloadDecoder) fail(message string) {
+	if d.err == nil {
+		d.err = errors.New(message)
+	}
+	d.data = nil
+}
+
+func (d *payloadDecoder) int() int {
+	n, size := binary.Uvarint(d.data)
+	if size <= 0 || n > math.MaxInt32 {
+		d.fail("bad integer")
+		return 0
+	}
+	d.data = d.data[size:]
+	return int(n)
+}
+
+func (d *payloadDecoder) bytes(n int) []byte {
+	if n > len(d.data) {
+		d.fail("unexpected end of data")
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *payloadDecoder) string() string {
+	return string(d.bytes(d.int()))
+}
+
+func (d *payloadDecoder) function() *CompiledFunction {
+	function := &CompiledFunction{Name: d.string(), Arity: d.int(), UpvalueCount: d.int()}
+	chunk := &function.Chunk
+	chunk.Code = append([]byte{}, d.bytes(d.int())...)
+
+	for count := d.int(); count > 0 && d.err == nil; count-- {
+		switch tag := d.bytes(1); {
+		case len(tag) == 0:
+		case tag[0] == constantInt:
+			if bits := d.bytes(8); bits != nil {
+				chunk.Constants = append(chunk.Constants, IntValue(int64(binary.BigEndian.Uint64(bits))))
+			}
+		case tag[0] == constantFloat:
+			if bits := d.bytes(8); bits != nil {
+				chunk.Constants = append(chunk.Constants, FloatValue(math.Float64frombits(binary.BigEndian.Uint64(bits))))
+			}
+		case tag[0] == constantString:
+			chunk.Constants = append(chunk.Constants, StringValue(d.string()))
+		case tag[0] == constantFunction:
+			chunk.Constants = append(chunk.Constants, ObjectValue(d.function()))
+		default:
+			d.fail(fmt.Sprintf("unknown constant tag %d", tag[0]))
+		}
+	}
+
+	for count := d.int(); count > 0 && d.err == nil; count-- {
+		line := LineStart{Offset: d.int()}
+		line.Token.TokenType = TokenType(d.int())
+		line.Token.Lexeme = d.string()
+		line.Token.Line, line.Token.Column = d.int(), d.int()
+		line.Token.Offset, line.Token.Length = d.int(), d.int()
+		chunk.Lines = append(chunk.Lines, line)
+	}
+	return function
+}
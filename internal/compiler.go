@@ -0,0 +1,598 @@
+package internal
+
+import "math"
+
+type compiledKind int
+
+const (
+	compilingScript compiledKind = iota
+	compilingFunction
+	compilingMethod
+	compilingInitializer
+)
+
+// compilerLocal is a local variable slot. depth is -1 between declaration
+// and the end of the initializer.
+type compilerLocal struct {
+	name     string
+	depth    int
+	captured bool
+}
+
+type compilerUpvalue struct {
+	index   byte
+	isLocal bool
+}
+
+// functionCompiler holds what the Compiler knows about the function whose
+// body it is compiling; enclosing is the function around it.
+type functionCompiler struct {
+	enclosing  *functionCompiler
+	function   *CompiledFunction
+	kind       compiledKind
+	locals     []compilerLocal
+	upvalues   []compilerUpvalue
+	scopeDepth int
+}
+
+// Compiler turns a resolved program into bytecode for the VM. Like the
+// Resolver it works out at compile time which scope each variable lives in,
+// so the VM finds locals by slot instead of by name.
+type Compiler struct {
+	current *functionCompiler
+	token   Token
+	Errors  []error
+}
+
+// Compile compiles statements into the function for the top-level script.
+// The statements should already have passed the Resolver.
+func Compile(statements []Stmt) (*CompiledFunction, []error) {
+	c := &Compiler{}
+	c.beginFunction(compilingScript, "")
+	for _, stmt := range statements {
+		stmt.Apply(c)
+	}
+	return c.endFunction(), c.Errors
+}
+
+func (c *Compiler) error(token Token, message string) {
+	c.Errors = append(c.Errors, &CompileError{Token: token, Message: message})
+}
+
+func (c *Compiler) beginFunction(kind compiledKind, name string) {
+	c.current = &functionCompiler{
+		enclosing: c.current,
+		function:  &CompiledFunction{Name: name},
+		kind:      kind,
+	}
+	// Slot zero holds the function being called, or `this' in methods.
+	slotZero := ""
+	if kind == compilingMethod || kind == compilingInitializer {
+		slotZero = "this"
+	}
+	c.current.locals = append(c.current.locals, compilerLocal{name: slotZero})
+}
+
+func (c *Compiler) endFunction() *CompiledFunction {
+	c.emitReturn()
+	function := c.current.function
+	function.UpvalueCount = len(c.current.upvalues)
+	c.current = c.current.enclosing
+	return function
+}
+
+func (c *Compiler) function(stmt FunctionStmt, kind compiledKind) {
+	c.beginFunction(kind, stmt.Name.Lexeme)
+	c.current.function.Arity = len(stmt.Params)
+	c.beginScope()
+	for _, param := range stmt.Params {
+		c.declareVariable(param)
+		c.current.locals[len(c.current.locals)-1].depth = c.current.scopeDepth
+	}
+	for _, body := range stmt.Body {
+		body.Apply(c)
+	}
+	c.token = stmt.Name
+	upvalues := c.current.upvalues
+	function := c.endFunction()
+
+	c.emitOp(OpClosure)
+	c.emitShort(c.makeConstant(ObjectValue(function)))
+	for _, upvalue := range upvalues {
+		c.emitBool(upvalue.isLocal)
+		c.emitByte(upvalue.index)
+	}
+}
+
+// emitting
+
+func (c *Compiler) chunk() *Chunk {
+	return &c.current.function.Chunk
+}
+
+func (c *Compiler) emitByte(b byte) {
+	c.chunk().write(b, c.token)
+}
+
+func (c *Compiler) emitOp(op OpCode) {
+	c.emitByte(byte(op))
+}
+
+func (c *Compiler) emitBool(b bool) {
+	if b {
+		c.emitByte(1)
+	} else {
+		c.emitByte(0)
+	}
+}
+
+func (c *Compiler) emitShort(n int) {
+	c.emitByte(byte(n >> 8))
+	c.emitByte(byte(n))
+}
+
+func (c *Compiler) emitReturn() {
+	if c.current.kind == compilingInitializer {
+		c.emitOp(OpGetLocal)
+		c.emitByte(0)
+	} else {
+		c.emitOp(OpNil)
+	}
+	c.emitOp(OpReturn)
+}
+
+func (c *Compiler) makeConstant(value Value) int {
+	index := c.chunk().addConstant(value)
+	if index > math.MaxUint16 {
+		c.error(c.token, "Too many constants in one chunk.")
+		return 0
+	}
+	return index
+}
+
+func (c *Compiler) emitConstant(value Value) {
+	c.emitOp(OpConstant)
+	c.emitShort(c.makeConstant(value))
+}
+
+// emitJump writes a jump with a placeholder distance and returns where the
+// distance goes, for patchJump.
+func (c *Compiler) emitJump(op OpCode) int {
+	c.emitOp(op)
+	c.emitShort(0xffff)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *Compiler) patchJump(at int) {
+	distance := len(c.chunk().Code) - at - 2
+	if distance > math.MaxUint16 {
+		c.error(c.token, "Too much code to jump over.")
+	}
+	c.chunk().Code[at] = byte(distance >> 8)
+	c.chunk().Code[at+1] = byte(distance)
+}
+
+func (c *Compiler) emitLoop(start int) {
+	c.emitOp(OpLoop)
+	distance := len(c.chunk().Code) - start + 2
+	if distance > math.MaxUint16 {
+		c.error(c.token, "Loop body too large.")
+	}
+	c.emitShort(distance)
+}
+
+// scopes and variables
+
+func (c *Compiler) beginScope() {
+	c.current.scopeDepth++
+}
+
+func (c *Compiler) endScope() {
+	c.current.scopeDepth--
+	locals := c.current.locals
+	for len(locals) > 0 && locals[len(locals)-1].depth > c.current.scopeDepth {
+		if locals[len(locals)-1].captured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+		locals = locals[:len(locals)-1]
+	}
+	c.current.locals = locals
+}
+
+// declareVariable adds a local for name, unless it is a global. The local
+// stays unusable until markInitialized.
+func (c *Compiler) declareVariable(name Token) {
+	if c.current.scopeDepth == 0 {
+		return
+	}
+	if len(c.current.locals) > math.MaxUint8 {
+		c.error(name, "Too many local variables in function.")
+		return
+	}
+	c.current.locals = append(c.current.locals, compilerLocal{name: name.Lexeme, depth: -1})
+}
+
+// defineVariable finishes a declaration whose value is on the stack.
+func (c *Compiler) defineVariable(name Token) {
+	if c.current.scopeDepth > 0 {
+		c.current.locals[len(c.current.locals)-1].depth = c.current.scopeDepth
+		return
+	}
+	c.token = name
+	c.emitOp(OpDefineGlobal)
+	c.emitShort(c.makeConstant(StringValue(name.Lexeme)))
+}
+
+func resolveLocal(compiler *functionCompiler, name string) int {
+	for n := len(compiler.locals) - 1; n >= 0; n-- {
+		if compiler.locals[n].name == name {
+			return n
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) resolveUpvalue(compiler *functionCompiler, name string) int {
+	if compiler.enclosing == nil {
+		return -1
+	}
+	if local := resolveLocal(compiler.enclosing, name); local >= 0 {
+		compiler.enclosing.locals[local].captured = true
+		return c.addUpvalue(compiler, byte(local), true)
+	}
+	if upvalue := c.resolveUpvalue(compiler.enclosing, name); upvalue >= 0 {
+		return c.addUpvalue(compiler, byte(upvalue), false)
+	}
+	return -1
+}
+
+func (c *Compiler) addUpvalue(compiler *functionCompiler, index byte, isLocal bool) int {
+	for n, upvalue := range compiler.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return n
+		}
+	}
+	if len(compiler.upvalues) > math.MaxUint8 {
+		c.error(c.token, "Too many closure variables in function.")
+		return 0
+	}
+	compiler.upvalues = append(compiler.upvalues, compilerUpvalue{index, isLocal})
+	return len(compiler.upvalues) - 1
+}
+
+// variable emits a read of name, or a write of the value on top of the
+// stack when assign is set.
+func (c *Compiler) variable(name Token, assign bool) {
+	c.token = name
+	getOp, setOp, operand := OpGetGlobal, OpSetGlobal, -1
+	if slot := resolveLocal(c.current, name.Lexeme); slot >= 0 {
+		getOp, setOp, operand = OpGetLocal, OpSetLocal, slot
+	} else if slot := c.resolveUpvalue(c.current, name.Lexeme); slot >= 0 {
+		getOp, setOp, operand = OpGetUpvalue, OpSetUpvalue, slot
+	}
+
+	if assign {
+		c.emitOp(setOp)
+	} else {
+		c.emitOp(getOp)
+	}
+	if operand >= 0 {
+		c.emitByte(byte(operand))
+	} else {
+		c.emitShort(c.makeConstant(StringValue(name.Lexeme)))
+	}
+}
+
+// statements
+
+func (c *Compiler) VisitExpression(stmt Expression) any {
+	stmt.Expr.Apply(c)
+	c.emitOp(OpPop)
+	return nil
+}
+
+func (c *Compiler) VisitPrint(stmt Print) any {
+	stmt.Expr.Apply(c)
+	c.emitOp(OpPrint)
+	return nil
+}
+
+func (c *Compiler) VisitVarDeclare(stmt VarDeclare) any {
+	c.declareVariable(stmt.Name)
+	if stmt.InitialExpr != nil {
+		stmt.InitialExpr.Apply(c)
+	} else {
+		c.emitOp(OpNil)
+	}
+	c.defineVariable(stmt.Name)
+	return nil
+}
+
+func (c *Compiler) VisitBlock(stmt Block) any {
+	c.beginScope()
+	for _, inner := range stmt.Statements {
+		inner.Apply(c)
+	}
+	c.endScope()
+	return nil
+}
+
+func (c *Compiler) VisitIfStmt(stmt IfStmt) any {
+	stmt.Condition.Apply(c)
+	thenJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	stmt.ThenBranch.Apply(c)
+	elseJump := c.emitJump(OpJump)
+
+	c.patchJump(thenJump)
+	c.emitOp(OpPop)
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch.Apply(c)
+	}
+	c.patchJump(elseJump)
+	return nil
+}
+
+func (c *Compiler) VisitWhileStmt(stmt WhileStmt) any {
+	start := len(c.chunk().Code)
+	stmt.Condition.Apply(c)
+	exitJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	stmt.Body.Apply(c)
+	c.emitLoop(start)
+
+	c.patchJump(exitJump)
+	c.emitOp(OpPop)
+	return nil
+}
+
+func (c *Compiler) VisitFunctionStmt(stmt FunctionStmt) any {
+	c.declareVariable(stmt.Name)
+	if c.current.scopeDepth > 0 {
+		// A local function may call itself, so it is usable in its own body.
+		c.current.locals[len(c.current.locals)-1].depth = c.current.scopeDepth
+	}
+	c.function(stmt, compilingFunction)
+	c.defineVariable(stmt.Name)
+	return nil
+}
+
+func (c *Compiler) VisitReturnStmt(stmt ReturnStmt) any {
+	c.token = stmt.Keyword
+	if stmt.Value == nil {
+		c.emitReturn()
+		return nil
+	}
+	stmt.Value.Apply(c)
+	c.emitOp(OpReturn)
+	return nil
+}
+
+func (c *Compiler) VisitClassStmt(stmt ClassStmt) any {
+	c.token = stmt.Name
+	name := c.makeConstant(StringValue(stmt.Name.Lexeme))
+	c.declareVariable(stmt.Name)
+	c.emitOp(OpClass)
+	c.emitShort(name)
+	c.defineVariable(stmt.Name)
+
+	if stmt.Superclass != nil {
+		c.variable(stmt.Superclass.Name, false)
+		c.beginScope()
+		c.current.locals = append(c.current.locals, compilerLocal{name: "super", depth: c.current.scopeDepth})
+		c.variable(stmt.Name, false)
+		c.token = stmt.Superclass.Name
+		c.emitOp(OpInherit)
+	}
+
+	c.variable(stmt.Name, false)
+	for _, method := range stmt.Methods {
+		kind := compilingMethod
+		if method.Name.Lexeme == "init" {
+			kind = compilingInitializer
+		}
+		c.function(*method, kind)
+		c.token = method.Name
+		c.emitOp(OpMethod)
+		c.emitShort(c.makeConstant(StringValue(method.Name.Lexeme)))
+	}
+	c.emitOp(OpPop)
+
+	if stmt.Superclass != nil {
+		c.endScope()
+	}
+	return nil
+}
+
+// expressions
+
+func (c *Compiler) VisitBinaryExpr(expr *Binary) any {
+	expr.Left.Apply(c)
+	expr.Right.Apply(c)
+	c.token = expr.Operator
+	switch expr.Operator.TokenType {
+	case PLUS:
+		c.emitOp(OpAdd)
+	case MINUS:
+		c.emitOp(OpSubtract)
+	case STAR:
+		c.emitOp(OpMultiply)
+	case SLASH:
+		c.emitOp(OpDivide)
+	case TILDE_SLASH:
+		c.emitOp(OpIntDivide)
+	case PERCENT:
+		c.emitOp(OpModulo)
+	case EQUAL_EQUAL:
+		c.emitOp(OpEqual)
+	case BANG_EQUAL:
+		c.emitOp(OpNotEqual)
+	case GREATER:
+		c.emitOp(OpGreater)
+	case GREATER_EQUAL:
+		c.emitOp(OpGreaterEqual)
+	case LESS:
+		c.emitOp(OpLess)
+	case LESS_EQUAL:
+		c.emitOp(OpLessEqual)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitUnaryExpr(expr *Unary) any {
+	expr.Right.Apply(c)
+	c.token = expr.Operator
+	switch expr.Operator.TokenType {
+	case MINUS:
+		c.emitOp(OpNegate)
+	case BANG:
+		c.emitOp(OpNot)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitLiteralExpr(expr *Literal) any {
+	c.token = expr.Token
+	switch value := expr.Value.(type) {
+	case nil:
+		c.emitOp(OpNil)
+	case bool:
+		if value {
+			c.emitOp(OpTrue)
+		} else {
+			c.emitOp(OpFalse)
+		}
+	default:
+		c.emitConstant(LiteralValue(value))
+	}
+	return nil
+}
+
+// VisitInterpolationExpr pushes the non-empty segments and the values in
+// order and joins them with OpInterpolate, in runs of at most 255.
+func (c *Compiler) VisitInterpolationExpr(expr *Interpolation) any {
+	parts := 0
+	join := func() {
+		if parts == math.MaxUint8 {
+			c.emitOp(OpInterpolate)
+			c.emitByte(byte(parts))
+			parts = 1
+		}
+	}
+	for n, segment := range expr.Segments {
+		if text := segment.Literal.(string); text != "" || len(expr.Expressions) == 0 {
+			c.token = segment
+			c.emitConstant(StringValue(text))
+			parts++
+			join()
+		}
+		if n < len(expr.Expressions) {
+			expr.Expressions[n].Apply(c)
+			parts++
+			join()
+		}
+	}
+	c.token = expr.Segments[0]
+	c.emitOp(OpInterpolate)
+	c.emitByte(byte(parts))
+	return nil
+}
+
+func (c *Compiler) VisitGroupingExpr(expr *Grouping) any {
+	expr.Inside.Apply(c)
+	return nil
+}
+
+func (c *Compiler) VisitVariableExpr(expr *Variable) any {
+	c.variable(expr.Name, false)
+	return nil
+}
+
+func (c *Compiler) VisitAssignmentExpr(expr *Assignment) any {
+	expr.Value.Apply(c)
+	c.variable(expr.Name, true)
+	return nil
+}
+
+func (c *Compiler) VisitCallExpr(expr *Call) any {
+	expr.Callee.Apply(c)
+	for _, arg := range expr.Arguments {
+		arg.Apply(c)
+	}
+	c.token = expr.paren
+	if len(expr.Arguments) > math.MaxUint8 {
+		c.error(expr.paren, "Can't have more than 255 arguments.")
+	}
+	c.emitOp(OpCall)
+	c.emitByte(byte(len(expr.Arguments)))
+	return nil
+}
+
+// VisitLogicalExpr leaves true or false rather than an operand, as the
+// Interpreter does: `a or b' is true when a is truthy and otherwise the
+// truthiness of b.
+func (c *Compiler) VisitLogicalExpr(expr *Logic) any {
+	expr.Left.Apply(c)
+	c.token = expr.Operator
+	shortCircuit := c.emitJump(OpJumpIfFalse)
+	if expr.Operator.TokenType == OR {
+		c.emitOp(OpPop)
+		c.emitOp(OpTrue)
+		end := c.emitJump(OpJump)
+		c.patchJump(shortCircuit)
+		c.emitOp(OpPop)
+		c.truthiness(expr.Right)
+		c.patchJump(end)
+		return nil
+	}
+
+	c.emitOp(OpPop)
+	c.truthiness(expr.Right)
+	end := c.emitJump(OpJump)
+	c.patchJump(shortCircuit)
+	c.emitOp(OpPop)
+	c.emitOp(OpFalse)
+	c.patchJump(end)
+	return nil
+}
+
+func (c *Compiler) truthiness(expr Expr) {
+	expr.Apply(c)
+	c.emitOp(OpNot)
+	c.emitOp(OpNot)
+}
+
+func (c *Compiler) VisitGetExpr(expr *Get) any {
+	expr.Object.Apply(c)
+	c.token = expr.Name
+	c.emitOp(OpGetProperty)
+	c.emitShort(c.makeConstant(StringValue(expr.Name.Lexeme)))
+	return nil
+}
+
+func (c *Compiler) VisitSetExpr(expr *Set) any {
+	expr.Object.Apply(c)
+	expr.Value.Apply(c)
+	c.token = expr.Name
+	c.emitOp(OpSetProperty)
+	c.emitShort(c.makeConstant(StringValue(expr.Name.Lexeme)))
+	return nil
+}
+
+func (c *Compiler) VisitThisExpr(expr *This) any {
+	c.variable(expr.Keyword, false)
+	return nil
+}
+
+func (c *Compiler) VisitSuperExpr(expr *Super) any {
+	this := expr.Keyword
+	this.TokenType, this.Lexeme = THIS, "this"
+	c.variable(this, false)
+	c.variable(expr.Keyword, false)
+	c.token = expr.Method
+	c.emitOp(OpGetSuper)
+	c.emitShort(c.makeConstant(StringValue(expr.Method.Lexeme)))
+	return nil
+}
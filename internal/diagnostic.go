@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// Diagnostic is a problem found in a source file, independent of how it is
+// going to be shown. Code names the stage that found it, e.g. "parse-error",
+// or the lint rule.
+type Diagnostic struct {
+	Severity   Severity
+	Code       string
+	Message    string
+	Span       Span
+	Notes      []Note
+	Suggestion string
+}
+
+// NewDiagnostic converts one of the Scanner, Parser, Resolver, Compiler,
+// Interpreter or Linter errors into a Diagnostic. Other errors get an empty
+// span.
+func NewDiagnostic(err error) Diagnostic {
+	switch err := err.(type) {
+	case *ScanError:
+		return Diagnostic{SeverityError, "scan-error", err.Message, err.Token.Span(), err.Notes, err.Suggestion}
+	case *ParseError:
+		return Diagnostic{SeverityError, "parse-error", err.Message, err.Token.Span(), err.Notes, err.Suggestion}
+	case *ResolveError:
+		return Diagnostic{SeverityError, "resolve-error", err.Message, err.Token.Span(), err.Notes, err.Suggestion}
+	case *ResolveWarning:
+		return Diagnostic{SeverityWarning, "resolve-warning", err.Message, err.Token.Span(), err.Notes, err.Suggestion}
+	case *CompileError:
+		return Diagnostic{SeverityError, "compile-error", err.Message, err.Token.Span(), err.Notes, err.Suggestion}
+	case *RuntimeError:
+		return Diagnostic{SeverityError, "runtime-error", err.Message, err.Token.Span(), err.Notes, err.Suggestion}
+	case *LintWarning:
+		return Diagnostic{SeverityWarning, err.Rule, err.Message, err.Span, err.Notes, err.Suggestion}
+	default:
+		return Diagnostic{Severity: SeverityError, Code: "error", Message: err.Error()}
+	}
+}
+
+// Check runs every static stage over source without executing it: scanning,
+// parsing and, when those succeed, resolution. The Resolver's warnings come
+// after its errors; use HasErrors to tell whether the program may run.
+func Check(source []byte) []error {
+	scanner := NewScanner(source)
+	tokens, scanErrors := scanner.ScanTokens()
+	parser := NewParser(tokens)
+	statements, parseErrors := parser.Parse()
+	return checkParsed(statements, scanErrors, parseErrors)
+}
+
+// checkParsed finishes Check for a program that has already been scanned
+// and parsed.
+func checkParsed(statements []Stmt, scanErrors, parseErrors []error) []error {
+	if staticErrors := append(scanErrors, parseErrors...); len(staticErrors) > 0 {
+		return staticErrors
+	}
+	resolver := NewResolver(NewInterpreter())
+	return append(resolver.Resolve(statements), resolver.Warnings...)
+}
+
+// HasErrors reports whether any of errs is more than a warning.
+func HasErrors(errs []error) bool {
+	for _, err := range errs {
+		if NewDiagnostic(err).Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// DiagnosticPrinter renders diagnostics against the source they refer to:
+//
+//	script.gx:3:7: error: Undefined variable 'conut'.
+//	    3 | print conut;
+//	      |       ^~~~~
+//	      = help: did you mean 'count'?
+type DiagnosticPrinter struct {
+	File   string
+	Source []byte
+	Color  bool
+}
+
+func (p DiagnosticPrinter) Render(d Diagnostic) string {
+	var sb strings.Builder
+	start := d.Span.Start
+
+	severityColor := ansiRed
+	if d.Severity == SeverityWarning {
+		severityColor = ansiYellow
+	}
+	if start.Line > 0 {
+		sb.WriteString(p.paint(ansiBold, fmt.Sprintf("%s:%d:%d: ", p.File, start.Line, start.Column)))
+	} else {
+		sb.WriteString(p.paint(ansiBold, p.File+": "))
+	}
+	sb.WriteString(p.paint(ansiBold+severityColor, d.Severity.String()+":"))
+	sb.WriteString(p.paint(ansiBold, " "+d.Message))
+	sb.WriteString("\n")
+
+	gutter := strings.Repeat(" ", len(fmt.Sprint(start.Line))+5)
+	// A diagnostic without a span, such as one for an error of another
+	// kind, has no line to show.
+	if line, ok := p.line(start.Offset); ok && start.Line > 0 {
+		sb.WriteString(p.paint(ansiBlue, fmt.Sprintf("    %d | ", start.Line)))
+		sb.WriteString(line)
+		sb.WriteString("\n")
+		sb.WriteString(p.paint(ansiBlue, gutter+"| "))
+		sb.WriteString(p.underline(line, start.Column, d.Span, severityColor))
+		sb.WriteString("\n")
+	}
+
+	for _, note := range d.Notes {
+		sb.WriteString(gutter + p.paint(ansiBlue, "= ") + p.paint(ansiBold, "note: ") + note.Message + "\n")
+	}
+	if d.Suggestion != "" {
+		sb.WriteString(gutter + p.paint(ansiBlue, "= ") + p.paint(ansiBold+ansiCyan, "help: ") + fmt.Sprintf("did you mean '%s'?", d.Suggestion) + "\n")
+	}
+	return sb.String()
+}
+
+// RenderErrors renders every error, in order, as one string.
+func (p DiagnosticPrinter) RenderErrors(errs []error) string {
+	var sb strings.Builder
+	for _, err := range errs {
+		sb.WriteString(p.Render(NewDiagnostic(err)))
+	}
+	return sb.String()
+}
+
+// line returns the text of the source line containing offset.
+func (p DiagnosticPrinter) line(offset int) (string, bool) {
+	if p.Source == nil || offset < 0 || offset > len(p.Source) {
+		return "", false
+	}
+	start := bytes.LastIndexByte(p.Source[:offset], '\n') + 1
+	end := bytes.IndexByte(p.Source[offset:], '\n')
+	if end < 0 {
+		end = len(p.Source)
+	} else {
+		end += offset
+	}
+	return strings.TrimRight(string(p.Source[start:end]), "\r"), true
+}
+
+// underline puts `^' under the first character of span and `~' under the
+// rest of it, up to the end of line. Tabs before the span are kept so the
+// marker lines up with the source.
+func (p DiagnosticPrinter) underline(line string, column int, span Span, color string) string {
+	var indent strings.Builder
+	prefix := line[:min(max(column-1, 0), len(line))]
+	for _, r := range prefix {
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+
+	width := 1
+	if span.End.Line == span.Start.Line && span.End.Offset > span.Start.Offset {
+		width = utf8.RuneCountInString(string(p.Source[span.Start.Offset:min(span.End.Offset, len(p.Source))]))
+	} else if span.End.Offset > span.Start.Offset {
+		width = utf8.RuneCountInString(line[len(prefix):])
+	}
+	width = max(width, 1)
+	return indent.String() + p.paint(ansiBold+color, "^"+strings.Repeat("~", width-1))
+}
+
+// JSON renders errors as the JSON array printed by `golox check
+// --format=json':
+//
+//	[{"severity": "error", "code": "parse-error", "message": "...",
+//	  "file": "script.gx", "range": {"start": {...}, "end": {...}},
+//	  "related": [{"message": "...", "file": "script.gx", "range": {...}}],
+//	  "suggestion": "count"}]
+//
+// Lines and columns are 1-based, offsets are 0-based byte offsets. "range"
+// is null for problems that are not tied to a place in the source.
+func (p DiagnosticPrinter) JSON(errs []error) ([]byte, error) {
+	diagnostics := make([]jsonDiagnostic, len(errs))
+	for n, err := range errs {
+		d := NewDiagnostic(err)
+		diagnostics[n] = jsonDiagnostic{
+			Severity:   d.Severity.String(),
+			Code:       d.Code,
+			Message:    d.Message,
+			File:       p.File,
+			Range:      jsonSpan(d.Span),
+			Related:    make([]jsonRelated, len(d.Notes)),
+			Suggestion: d.Suggestion,
+		}
+		for i, note := range d.Notes {
+			diagnostics[n].Related[i] = jsonRelated{Message: note.Message, File: p.File, Range: jsonSpan(note.Span)}
+		}
+	}
+	return json.MarshalIndent(diagnostics, "", "  ")
+}
+
+type jsonDiagnostic struct {
+	Severity   string        `json:"severity"`
+	Code       string        `json:"code"`
+	Message    string        `json:"message"`
+	File       string        `json:"file"`
+	Range      *jsonRange    `json:"range"`
+	Related    []jsonRelated `json:"related"`
+	Suggestion string        `json:"suggestion,omitempty"`
+}
+
+type jsonRelated struct {
+	Message string     `json:"message"`
+	File    string     `json:"file"`
+	Range   *jsonRange `json:"range"`
+}
+
+type jsonRange struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+func jsonSpan(span Span) *jsonRange {
+	if span.Start.Line == 0 {
+		return nil
+	}
+	return &jsonRange{
+		Start: jsonPosition(span.Start),
+		End:   jsonPosition(span.End),
+	}
+}
+
+func (p DiagnosticPrinter) paint(code, text string) string {
+	if !p.Color {
+		return text
+	}
+	return code + text + ansiReset
+}
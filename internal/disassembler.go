@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Disassemble lists the bytecode of function and of every function nested
+// in it, one instruction per line:
+//
+//	== <fn add> ==
+//	-- 2 | return a + b;
+//	0000    2 OP_GET_LOCAL        1
+//	0002    | OP_GET_LOCAL        2
+//	0004    | OP_ADD
+//
+// The columns are the code offset, the source line, which is `|' when it is
+// the same as the instruction before, the opcode and its operands. With
+// source, each new line is preceded by its text.
+func Disassemble(function *CompiledFunction, source []byte) string {
+	d := disassembler{source: source}
+	d.function(function)
+	return d.sb.String()
+}
+
+type disassembler struct {
+	source []byte
+	sb     strings.Builder
+}
+
+func (d *disassembler) function(function *CompiledFunction) {
+	fmt.Fprintf(&d.sb, "== %v ==\n", function)
+	chunk := &function.Chunk
+	line := 0
+	for offset := 0; offset < len(chunk.Code); {
+		token := chunk.TokenAt(offset)
+		if token.Line != line {
+			if text, ok := d.sourceLine(token); ok {
+				fmt.Fprintf(&d.sb, "-- %d | %s\n", token.Line, text)
+			}
+			fmt.Fprintf(&d.sb, "%04d %4d ", offset, token.Line)
+			line = token.Line
+		} else {
+			fmt.Fprintf(&d.sb, "%04d    | ", offset)
+		}
+		offset = d.instruction(chunk, offset)
+	}
+
+	for _, constant := range chunk.Constants {
+		if nested, ok := constant.AsObject().(*CompiledFunction); ok {
+			d.sb.WriteString("\n")
+			d.function(nested)
+		}
+	}
+}
+
+// instruction writes the instruction at offset and returns the offset of
+// the next one.
+func (d *disassembler) instruction(chunk *Chunk, offset int) int {
+	op := OpCode(chunk.Code[offset])
+	short := func(at int) int {
+		if at+1 >= len(chunk.Code) {
+			return 0
+		}
+		return int(chunk.Code[at])<<8 | int(chunk.Code[at+1])
+	}
+	operand := func(at int) int {
+		if at >= len(chunk.Code) {
+			return 0
+		}
+		return int(chunk.Code[at])
+	}
+	constant := func(index int) Value {
+		if index < len(chunk.Constants) {
+			return chunk.Constants[index]
+		}
+		return StringValue("?")
+	}
+
+	switch op {
+	case OpConstant, OpGetGlobal, OpDefineGlobal, OpSetGlobal, OpGetProperty, OpSetProperty, OpGetSuper, OpClass, OpMethod:
+		index := short(offset + 1)
+		fmt.Fprintf(&d.sb, "%-16s %4d '%v'\n", op, index, constant(index))
+		return offset + 3
+	case OpGetLocal, OpSetLocal, OpGetUpvalue, OpSetUpvalue, OpCall, OpInterpolate:
+		fmt.Fprintf(&d.sb, "%-16s %4d\n", op, operand(offset+1))
+		return offset + 2
+	case OpJump, OpJumpIfFalse:
+		fmt.Fprintf(&d.sb, "%-16s %4d -> %d\n", op, offset, offset+3+short(offset+1))
+		return offset + 3
+	case OpLoop:
+		fmt.Fprintf(&d.sb, "%-16s %4d -> %d\n", op, offset, offset+3-short(offset+1))
+		return offset + 3
+	case OpClosure:
+		index := short(offset + 1)
+		function, _ := constant(index).AsObject().(*CompiledFunction)
+		fmt.Fprintf(&d.sb, "%-16s %4d %v\n", op, index, function)
+		offset += 3
+		if function == nil {
+			return offset
+		}
+		for range function.UpvalueCount {
+			kind := "upvalue"
+			if operand(offset) == 1 {
+				kind = "local"
+			}
+			fmt.Fprintf(&d.sb, "%04d    |                     %s %d\n", offset, kind, operand(offset+1))
+			offset += 2
+		}
+		return offset
+	default:
+		fmt.Fprintf(&d.sb, "%v\n", op)
+		return offset + 1
+	}
+}
+
+func (d *disassembler) sourceLine(token Token) (string, bool) {
+	if d.source == nil || token.Line == 0 || token.Offset > len(d.source) {
+		return "", false
+	}
+	start := bytes.LastIndexByte(d.source[:token.Offset], '\n') + 1
+	end := bytes.IndexByte(d.source[start:], '\n')
+	if end < 0 {
+		end = len(d.source) - start
+	}
+	return strings.TrimRight(string(d.source[start:start+end]), "\r"), true
+}
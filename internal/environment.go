@@ -2,18 +2,18 @@ package internal
 
 type Environment struct {
 	Enclosing *Environment
-	Variable  map[string]any
+	Variable  map[string]Value
 }
 
-func NewEnvironment(enclosing Environment) *Environment {
+func NewEnvironment(enclosing *Environment) *Environment {
 	return &Environment{
-		Enclosing: &enclosing,
-		Variable:  make(map[string]any),
+		Enclosing: enclosing,
+		Variable:  make(map[string]Value),
 	}
 }
 
-func (env *Environment) Get(name string) any {
-  if value, ok := env.Variable[name]; ok && value != nil {
+func (env *Environment) Get(name Token) Value {
+	if value, ok := env.Variable[name.Lexeme]; ok {
 		return value
 	}
 
@@ -21,16 +21,16 @@ func (env *Environment) Get(name string) any {
 		return env.Enclosing.Get(name)
 	}
 
-	panic("Undefined variable " + name)
+	panic(undefinedVariable(name, env))
 }
 
-func (env *Environment) Define(name string, value any) {
+func (env *Environment) Define(name string, value Value) {
 	env.Variable[name] = value
 }
 
-func (env *Environment) Assign(name string, value any) {
-	if _, exists := env.Variable[name]; exists {
-		env.Variable[name] = value
+func (env *Environment) Assign(name Token, value Value) {
+	if _, exists := env.Variable[name.Lexeme]; exists {
+		env.Variable[name.Lexeme] = value
 		return
 	}
 
@@ -38,9 +38,44 @@ func (env *Environment) Assign(name string, value any) {
 		env.Enclosing.Assign(name, value)
 		return
 	}
-	panic("Undefined variable " + name)
+	panic(undefinedVariable(name, env))
 }
 
-var env = Environment{
-	Variable: make(map[string]any),
+// Names lists every variable visible from env, innermost scope first.
+func (env *Environment) Names() []string {
+	names := []string{}
+	for scope := env; scope != nil; scope = scope.Enclosing {
+		for name := range scope.Variable {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// undefinedVariable builds the error for name, suggesting the closest name
+// visible from env.
+func undefinedVariable(name Token, env *Environment) *RuntimeError {
+	return &RuntimeError{
+		Token:      name,
+		Message:    "Undefined variable '" + name.Lexeme + "'.",
+		Suggestion: closestName(name.Lexeme, env.Names()),
+	}
+}
+
+// GetAt reads a variable the Resolver found exactly distance environments up
+// the chain.
+func (env *Environment) GetAt(distance int, name string) Value {
+	return env.Ancestor(distance).Variable[name]
+}
+
+func (env *Environment) AssignAt(distance int, name Token, value Value) {
+	env.Ancestor(distance).Variable[name.Lexeme] = value
+}
+
+func (env *Environment) Ancestor(distance int) *Environment {
+	ancestor := env
+	for i := 0; i < distance; i++ {
+		ancestor = ancestor.Enclosing
+	}
+	return ancestor
 }
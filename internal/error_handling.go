@@ -1,2 +1,110 @@
 package internal
 
+import "fmt"
+
+// Every error type below carries the Token it is about, so diagnostics can
+// point at the exact source span. Notes add context to the message and
+// Suggestion, when set, is a likely intended name ("did you mean ...?").
+
+// Note is extra context attached to an error. Span is the zero Span when the
+// note is not about a particular place in the source.
+type Note struct {
+	Message string
+	Span    Span
+}
+
+// ScanError is reported by the Scanner when it meets a character sequence
+// that cannot form a token. Scanning continues past it.
+type ScanError struct {
+	Token      Token
+	Message    string
+	Notes      []Note
+	Suggestion string
+}
+
+// ParseError is reported by the Parser when the token stream does not match
+// the grammar.
+type ParseError struct {
+	Token      Token
+	Message    string
+	Notes      []Note
+	Suggestion string
+}
+
+// ResolveError is reported by the Resolver for programs that parse but are
+// statically invalid, such as reading a local in its own initializer.
+type ResolveError struct {
+	Token      Token
+	Message    string
+	Notes      []Note
+	Suggestion string
+}
+
+// ResolveWarning is reported by the Resolver for code that is valid but
+// probably a mistake, such as a local that is never read. It does not stop
+// the program from running.
+type ResolveWarning struct {
+	Token      Token
+	Message    string
+	Notes      []Note
+	Suggestion string
+}
+
+// CompileError is reported by the Compiler for valid programs that exceed
+// a limit of the bytecode, such as more than 255 arguments in one call.
+type CompileError struct {
+	Token      Token
+	Message    string
+	Notes      []Note
+	Suggestion string
+}
+
+// RuntimeError aborts the Interpreter. Token is the part of the source that
+// was being evaluated when things went wrong.
+type RuntimeError struct {
+	Token      Token
+	Message    string
+	Notes      []Note
+	Suggestion string
+}
+
+// LintWarning is reported by the Linter for code that is valid but likely a
+// mistake. Rule names the check that found it.
+type LintWarning struct {
+	Rule       string
+	Span       Span
+	Message    string
+	Notes      []Note
+	Suggestion string
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Token.Line, e.Token.Column, e.Message)
+}
+
+func (e *ParseError) Error() string {
+	if e.Token.TokenType == EOF {
+		return fmt.Sprintf("%d:%d: at end: %s", e.Token.Line, e.Token.Column, e.Message)
+	}
+	return fmt.Sprintf("%d:%d: at '%s': %s", e.Token.Line, e.Token.Column, e.Token.Lexeme, e.Message)
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("%d:%d: at '%s': %s", e.Token.Line, e.Token.Column, e.Token.Lexeme, e.Message)
+}
+
+func (e *ResolveWarning) Error() string {
+	return fmt.Sprintf("%d:%d: at '%s': %s", e.Token.Line, e.Token.Column, e.Token.Lexeme, e.Message)
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("%d:%d: at '%s': %s", e.Token.Line, e.Token.Column, e.Token.Lexeme, e.Message)
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Token.Line, e.Token.Column, e.Message)
+}
+
+func (e *LintWarning) Error() string {
+	return fmt.Sprintf("%d:%d: %s [%s]", e.Span.Start.Line, e.Span.Start.Column, e.Message, e.Rule)
+}
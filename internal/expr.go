@@ -4,17 +4,23 @@ import "fmt"
 
 type Expr interface {
 	Apply(VisitorExpr) any
+	Span() Span
 }
 
 type VisitorExpr interface {
-	VisitBinaryExpr(expr Binary) any
-	VisitUnaryExpr(expr Unary) any
-	VisitLiteralExpr(expr Literal) any
-	VisitGroupingExpr(expr Grouping) any
-	VisitVariableExpr(expr Variable) any
-	VisitAssignmentExpr(expr Assignment) any
-	VisitCallExpr(expr Call) any
-	VisitLogicalExpr(expr Logic) any
+	VisitBinaryExpr(expr *Binary) any
+	VisitUnaryExpr(expr *Unary) any
+	VisitLiteralExpr(expr *Literal) any
+	VisitGroupingExpr(expr *Grouping) any
+	VisitVariableExpr(expr *Variable) any
+	VisitAssignmentExpr(expr *Assignment) any
+	VisitCallExpr(expr *Call) any
+	VisitLogicalExpr(expr *Logic) any
+	VisitGetExpr(expr *Get) any
+	VisitSetExpr(expr *Set) any
+	VisitThisExpr(expr *This) any
+	VisitSuperExpr(expr *Super) any
+	VisitInterpolationExpr(expr *Interpolation) any
 }
 
 type Binary struct {
@@ -30,10 +36,13 @@ type Unary struct {
 
 type Literal struct {
 	Value any
+	Token Token
 }
 
 type Grouping struct {
-	Inside Expr
+	Inside     Expr
+	LeftParen  Token
+	RightParen Token
 }
 
 type Variable struct {
@@ -51,43 +60,143 @@ type Call struct {
 	Arguments []Expr
 }
 
-
 type Logic struct {
 	Left     Expr
 	Operator Token
 	Right    Expr
 }
 
+type Get struct {
+	Object Expr
+	Name   Token
+}
+
+type Set struct {
+	Object Expr
+	Name   Token
+	Value  Expr
+}
+
+type This struct {
+	Keyword Token
+}
+
+type Super struct {
+	Keyword Token
+	Method  Token
+}
+
+// Interpolation is a string with expressions in it, "a ${b} c". Segments
+// are the INTERPOLATION token before each of Expressions and the STRING
+// token after the last one, so there is always one more segment than there
+// are expressions.
+type Interpolation struct {
+	Segments    []Token
+	Expressions []Expr
+}
+
 func (expr *Binary) Apply(v VisitorExpr) any {
-	return v.VisitBinaryExpr(*expr)
+	return v.VisitBinaryExpr(expr)
 }
 
 func (expr *Unary) Apply(v VisitorExpr) any {
-	return v.VisitUnaryExpr(*expr)
+	return v.VisitUnaryExpr(expr)
 }
 
 func (expr *Literal) Apply(v VisitorExpr) any {
-	return v.VisitLiteralExpr(*expr)
+	return v.VisitLiteralExpr(expr)
 }
 
 func (expr *Grouping) Apply(v VisitorExpr) any {
-	return v.VisitGroupingExpr(*expr)
+	return v.VisitGroupingExpr(expr)
 }
 
 func (expr *Variable) Apply(v VisitorExpr) any {
-	return v.VisitVariableExpr(*expr)
+	return v.VisitVariableExpr(expr)
 }
 
 func (expr *Assignment) Apply(v VisitorExpr) any {
-	return v.VisitAssignmentExpr(*expr)
+	return v.VisitAssignmentExpr(expr)
 }
 
 func (expr *Call) Apply(v VisitorExpr) any {
-	return v.VisitCallExpr(*expr)
+	return v.VisitCallExpr(expr)
 }
 
 func (expr *Logic) Apply(v VisitorExpr) any {
-	return v.VisitLogicalExpr(*expr)
+	return v.VisitLogicalExpr(expr)
+}
+
+func (expr *Get) Apply(v VisitorExpr) any {
+	return v.VisitGetExpr(expr)
+}
+
+func (expr *Set) Apply(v VisitorExpr) any {
+	return v.VisitSetExpr(expr)
+}
+
+func (expr *This) Apply(v VisitorExpr) any {
+	return v.VisitThisExpr(expr)
+}
+
+func (expr *Super) Apply(v VisitorExpr) any {
+	return v.VisitSuperExpr(expr)
+}
+
+func (expr *Interpolation) Apply(v VisitorExpr) any {
+	return v.VisitInterpolationExpr(expr)
+}
+
+func (expr *Binary) Span() Span {
+	return SpanBetween(expr.Left.Span(), expr.Right.Span())
+}
+
+func (expr *Unary) Span() Span {
+	return SpanBetween(expr.Operator.Span(), expr.Right.Span())
+}
+
+func (expr *Literal) Span() Span {
+	return expr.Token.Span()
+}
+
+func (expr *Grouping) Span() Span {
+	return SpanBetween(expr.LeftParen.Span(), expr.RightParen.Span())
+}
+
+func (expr *Variable) Span() Span {
+	return expr.Name.Span()
+}
+
+func (expr *Assignment) Span() Span {
+	return SpanBetween(expr.Name.Span(), expr.Value.Span())
+}
+
+func (expr *Call) Span() Span {
+	return SpanBetween(expr.Callee.Span(), expr.paren.Span())
+}
+
+func (expr *Logic) Span() Span {
+	return SpanBetween(expr.Left.Span(), expr.Right.Span())
+}
+
+func (expr *Get) Span() Span {
+	return SpanBetween(expr.Object.Span(), expr.Name.Span())
+}
+
+func (expr *Set) Span() Span {
+	return SpanBetween(expr.Object.Span(), expr.Value.Span())
+}
+
+func (expr *This) Span() Span {
+	return expr.Keyword.Span()
+}
+
+func (expr *Super) Span() Span {
+	return SpanBetween(expr.Keyword.Span(), expr.Method.Span())
+}
+
+func (expr *Interpolation) Span() Span {
+	return SpanBetween(expr.Segments[0].Span(), expr.Segments[len(expr.Segments)-1].Span())
 }
 
 func (expr *Binary) String() string {
@@ -117,3 +226,23 @@ func (expr *Assignment) String() string {
 func (expr *Logic) String() string {
 	return fmt.Sprintf("Logic(%v, %v, %v)", expr.Left, expr.Operator, expr.Right)
 }
+
+func (expr *Get) String() string {
+	return fmt.Sprintf("Get(%v, %v)", expr.Object, expr.Name)
+}
+
+func (expr *Set) String() string {
+	return fmt.Sprintf("Set(%v, %v, %v)", expr.Object, expr.Name, expr.Value)
+}
+
+func (expr *This) String() string {
+	return fmt.Sprintf("This(%v)", expr.Keyword)
+}
+
+func (expr *Super) String() string {
+	return fmt.Sprintf("Super(%v, %v)", expr.Keyword, expr.Method)
+}
+
+func (expr *Interpolation) String() string {
+	return fmt.Sprintf("Interpolation(%v, %v)", expr.Segments, expr.Expressions)
+}
@@ -0,0 +1,402 @@
+package internal
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// Formatter re-emits a program in the canonical style: two-space indents,
+// one space around binary operators, opening braces on the line of their
+// statement and at most one blank line between statements. Comments are
+// kept; an expression with a comment inside it is copied from the source as
+// written, so the comment stays where it was.
+type Formatter struct {
+	source   []byte
+	comments []Token
+	sb       strings.Builder
+	depth    int
+	// lastLine is the source line of whatever was emitted last, used to
+	// carry blank lines over from the source.
+	lastLine int
+}
+
+// Format formats source. Source with scan or parse errors is left alone and
+// the errors are returned instead.
+func Format(source []byte) ([]byte, []error) {
+	scanner := NewScanner(source)
+	tokens, scanErrors := scanner.ScanTokens()
+	parser := NewParser(tokens)
+	statements, parseErrors := parser.Parse()
+	if errs := append(scanErrors, parseErrors...); len(errs) > 0 {
+		return nil, errs
+	}
+
+	f := &Formatter{source: source, comments: scanner.Comments}
+	f.statements(statements, len(source))
+	return []byte(f.sb.String()), nil
+}
+
+// statements emits a statement list, and then the comments that come
+// before end, which is where the list is closed in the source.
+func (f *Formatter) statements(statements []Stmt, end int) {
+	first := true
+	for _, stmt := range statements {
+		span := stmt.Span()
+		first = f.leadingComments(span.Start.Offset, first)
+		f.blankLine(span.Start.Line, first)
+		f.indent()
+		stmt.Apply(f)
+		f.trailingComment(span.End, end)
+		f.sb.WriteString("\n")
+		first = false
+	}
+	f.leadingComments(end, first)
+}
+
+// leadingComments emits the comments that start before offset, each on its
+// own line, and reports whether the list is still at its first line.
+func (f *Formatter) leadingComments(offset int, first bool) bool {
+	for len(f.comments) > 0 && f.comments[0].Offset < offset {
+		comment := f.comments[0]
+		f.comments = f.comments[1:]
+		f.blankLine(comment.Line, first)
+		f.indent()
+		f.sb.WriteString(comment.Lexeme)
+		f.sb.WriteString("\n")
+		f.lastLine = max(f.lastLine, comment.Span().End.Line)
+		first = false
+	}
+	return first
+}
+
+// trailingComment appends a comment that shares the last line of a
+// statement, as in `x = 1; // one'. A comment past limit, where the
+// statement's list is closed, belongs to the enclosing statement instead.
+func (f *Formatter) trailingComment(end Position, limit int) {
+	f.lastLine = end.Line
+	if len(f.comments) > 0 && f.comments[0].Line == end.Line &&
+		f.comments[0].Offset >= end.Offset && f.comments[0].Offset < limit {
+		f.sb.WriteString(" ")
+		f.sb.WriteString(f.comments[0].Lexeme)
+		f.lastLine = f.comments[0].Span().End.Line
+		f.comments = f.comments[1:]
+	}
+}
+
+func (f *Formatter) blankLine(line int, first bool) {
+	if !first && line > f.lastLine+1 {
+		f.sb.WriteString("\n")
+	}
+}
+
+func (f *Formatter) indent() {
+	f.sb.WriteString(strings.Repeat("  ", f.depth))
+}
+
+// block emits `{', the statements and `}'. The opening brace goes on the
+// current line.
+func (f *Formatter) block(statements []Stmt, span Span) {
+	f.sb.WriteString("{")
+	f.lastLine = span.Start.Line
+	if len(statements) == 0 && !f.hasCommentBefore(span.End.Offset) {
+		f.sb.WriteString("}")
+		return
+	}
+	f.sb.WriteString("\n")
+	f.depth++
+	f.statements(statements, span.End.Offset)
+	f.depth--
+	f.indent()
+	f.sb.WriteString("}")
+}
+
+func (f *Formatter) hasCommentBefore(offset int) bool {
+	return len(f.comments) > 0 && f.comments[0].Offset < offset
+}
+
+// body emits the statement controlled by an if, else or loop: a block stays
+// on the same line, anything else goes on its own indented line.
+func (f *Formatter) body(stmt Stmt) {
+	if block, ok := f.asBlock(stmt); ok {
+		f.sb.WriteString(" ")
+		f.block(block.Statements, block.Range)
+		return
+	}
+	f.sb.WriteString("\n")
+	f.depth++
+	f.leadingComments(stmt.Span().Start.Offset, true)
+	f.indent()
+	stmt.Apply(f)
+	f.depth--
+}
+
+func (f *Formatter) asBlock(stmt Stmt) (Block, bool) {
+	return writtenBlock(f.source, stmt)
+}
+
+// writtenBlock returns stmt if it is a block written with braces, as opposed
+// to one the Parser made up for a `for' loop.
+func writtenBlock(source []byte, stmt Stmt) (Block, bool) {
+	block, ok := stmt.(Block)
+	return block, ok && source[block.Range.Start.Offset] == '{'
+}
+
+// expr formats expr on one line, unless a comment falls inside it: then the
+// source text is kept as it is and the comment is dropped from the queue.
+func (f *Formatter) expr(expr Expr) string {
+	span := expr.Span()
+	first := sort.Search(len(f.comments), func(n int) bool {
+		return f.comments[n].Offset >= span.Start.Offset
+	})
+	last := first
+	for last < len(f.comments) && f.comments[last].Offset < span.End.Offset {
+		last++
+	}
+	if first == last {
+		return expr.Apply(f).(string)
+	}
+	f.comments = append(f.comments[:first:first], f.comments[last:]...)
+	return string(f.source[span.Start.Offset:span.End.Offset])
+}
+
+func (f *Formatter) expressions(expressions []Expr) string {
+	parts := make([]string, len(expressions))
+	for n, expr := range expressions {
+		parts[n] = f.expr(expr)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (f *Formatter) function(stmt FunctionStmt) {
+	params := make([]string, len(stmt.Params))
+	for n, param := range stmt.Params {
+		params[n] = param.Lexeme
+	}
+	f.sb.WriteString(stmt.Name.Lexeme + "(" + strings.Join(params, ", ") + ") ")
+	f.block(stmt.Body, stmt.Range)
+}
+
+// forLoop recognises the Block and WhileStmt nodes that the Parser builds
+// for a `for' statement. They all share the span of the `for' statement,
+// which no statement written by hand can have.
+func (f *Formatter) forLoop(stmt Stmt) bool {
+	span := stmt.Span()
+	if !bytes.HasPrefix(f.source[span.Start.Offset:], []byte("for")) {
+		return false
+	}
+
+	var initializer Stmt
+	loop, ok := asWhile(stmt)
+	if block, isBlock := stmt.(Block); isBlock && len(block.Statements) == 2 {
+		initializer = block.Statements[0]
+		loop, ok = asWhile(block.Statements[1])
+	}
+	if !ok || loop.Range != span {
+		return false
+	}
+
+	condition := ""
+	if literal, isLiteral := loop.Condition.(*Literal); !isLiteral || literal.Token.TokenType != FOR {
+		condition = f.expr(loop.Condition)
+	}
+	body, increment := loop.Body, ""
+	if block, isBlock := body.(Block); isBlock && block.Range == span {
+		body = block.Statements[0]
+		increment = f.expr(block.Statements[1].(Expression).Expr)
+	}
+
+	f.sb.WriteString("for (")
+	switch initializer := initializer.(type) {
+	case nil:
+		f.sb.WriteString(";")
+	case VarDeclare:
+		f.VisitVarDeclare(initializer)
+	case Expression:
+		f.VisitExpression(initializer)
+	}
+	if condition != "" {
+		f.sb.WriteString(" " + condition)
+	}
+	f.sb.WriteString(";")
+	if increment != "" {
+		f.sb.WriteString(" " + increment)
+	}
+	f.sb.WriteString(")")
+	f.body(body)
+	return true
+}
+
+func asWhile(stmt Stmt) (WhileStmt, bool) {
+	switch stmt := stmt.(type) {
+	case WhileStmt:
+		return stmt, true
+	case *WhileStmt:
+		return *stmt, true
+	}
+	return WhileStmt{}, false
+}
+
+// statements
+
+func (f *Formatter) VisitExpression(stmt Expression) any {
+	f.sb.WriteString(f.expr(stmt.Expr) + ";")
+	return nil
+}
+
+func (f *Formatter) VisitPrint(stmt Print) any {
+	f.sb.WriteString("print " + f.expr(stmt.Expr) + ";")
+	return nil
+}
+
+func (f *Formatter) VisitVarDeclare(stmt VarDeclare) any {
+	if stmt.InitialExpr == nil {
+		f.sb.WriteString("var " + stmt.Name.Lexeme + ";")
+		return nil
+	}
+	f.sb.WriteString("var " + stmt.Name.Lexeme + " = " + f.expr(stmt.InitialExpr) + ";")
+	return nil
+}
+
+func (f *Formatter) VisitBlock(stmt Block) any {
+	if !f.forLoop(stmt) {
+		f.block(stmt.Statements, stmt.Range)
+	}
+	return nil
+}
+
+func (f *Formatter) VisitIfStmt(stmt IfStmt) any {
+	f.sb.WriteString("if (" + f.expr(stmt.Condition) + ")")
+	f.body(stmt.ThenBranch)
+	if stmt.ElseBranch == nil {
+		return nil
+	}
+
+	if _, isBlock := f.asBlock(stmt.ThenBranch); isBlock {
+		f.sb.WriteString(" else")
+	} else {
+		f.sb.WriteString("\n")
+		f.indent()
+		f.sb.WriteString("else")
+	}
+	if elseIf, isIf := stmt.ElseBranch.(IfStmt); isIf {
+		f.sb.WriteString(" ")
+		f.VisitIfStmt(elseIf)
+		return nil
+	}
+	f.body(stmt.ElseBranch)
+	return nil
+}
+
+func (f *Formatter) VisitWhileStmt(stmt WhileStmt) any {
+	if !f.forLoop(stmt) {
+		f.sb.WriteString("while (" + f.expr(stmt.Condition) + ")")
+		f.body(stmt.Body)
+	}
+	return nil
+}
+
+func (f *Formatter) VisitFunctionStmt(stmt FunctionStmt) any {
+	f.sb.WriteString("fun ")
+	f.function(stmt)
+	return nil
+}
+
+func (f *Formatter) VisitReturnStmt(stmt ReturnStmt) any {
+	if stmt.Value == nil {
+		f.sb.WriteString("return;")
+		return nil
+	}
+	f.sb.WriteString("return " + f.expr(stmt.Value) + ";")
+	return nil
+}
+
+func (f *Formatter) VisitClassStmt(stmt ClassStmt) any {
+	f.sb.WriteString("class " + stmt.Name.Lexeme)
+	if stmt.Superclass != nil {
+		f.sb.WriteString(" < " + stmt.Superclass.Name.Lexeme)
+	}
+	f.sb.WriteString(" ")
+
+	methods := make([]Stmt, len(stmt.Methods))
+	for n, method := range stmt.Methods {
+		methods[n] = classMethod{method}
+	}
+	f.block(methods, stmt.Range)
+	return nil
+}
+
+// classMethod lets methods go through statements() like any other list,
+// so they get the same comment and blank line handling, while printing
+// without the `fun' keyword.
+type classMethod struct{ *FunctionStmt }
+
+func (m classMethod) Apply(v VisitorStmt) any {
+	v.(*Formatter).function(*m.FunctionStmt)
+	return nil
+}
+
+// expressions
+
+func (f *Formatter) VisitBinaryExpr(expr *Binary) any {
+	return f.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + f.expr(expr.Right)
+}
+
+func (f *Formatter) VisitUnaryExpr(expr *Unary) any {
+	return expr.Operator.Lexeme + f.expr(expr.Right)
+}
+
+// VisitLiteralExpr keeps the literal as written, so `1.50' is not turned
+// into `1.5' and strings keep their quotes.
+func (f *Formatter) VisitLiteralExpr(expr *Literal) any {
+	return string(f.source[expr.Token.Offset : expr.Token.Offset+expr.Token.Length])
+}
+
+// VisitInterpolationExpr keeps the text of the string as written and
+// formats the expressions in it.
+func (f *Formatter) VisitInterpolationExpr(expr *Interpolation) any {
+	var sb strings.Builder
+	for n, segment := range expr.Segments {
+		sb.Write(f.source[segment.Offset : segment.Offset+segment.Length])
+		if n < len(expr.Expressions) {
+			sb.WriteString(f.expr(expr.Expressions[n]))
+		}
+	}
+	return sb.String()
+}
+
+func (f *Formatter) VisitGroupingExpr(expr *Grouping) any {
+	return "(" + f.expr(expr.Inside) + ")"
+}
+
+func (f *Formatter) VisitVariableExpr(expr *Variable) any {
+	return expr.Name.Lexeme
+}
+
+func (f *Formatter) VisitAssignmentExpr(expr *Assignment) any {
+	return expr.Name.Lexeme + " = " + f.expr(expr.Value)
+}
+
+func (f *Formatter) VisitCallExpr(expr *Call) any {
+	return f.expr(expr.Callee) + "(" + f.expressions(expr.Arguments) + ")"
+}
+
+func (f *Formatter) VisitLogicalExpr(expr *Logic) any {
+	return f.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + f.expr(expr.Right)
+}
+
+func (f *Formatter) VisitGetExpr(expr *Get) any {
+	return f.expr(expr.Object) + "." + expr.Name.Lexeme
+}
+
+func (f *Formatter) VisitSetExpr(expr *Set) any {
+	return f.expr(expr.Object) + "." + expr.Name.Lexeme + " = " + f.expr(expr.Value)
+}
+
+func (f *Formatter) VisitThisExpr(expr *This) any {
+	return "this"
+}
+
+func (f *Formatter) VisitSuperExpr(expr *Super) any {
+	return "super." + expr.Method.Lexeme
+}
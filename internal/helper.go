@@ -1,21 +1,80 @@
 package internal
 
+import "unicode"
+
 func isDigit(v byte) bool {
 	return '0' <= v && v <= '9'
 }
 
-func isAlpha(v byte) bool {
-	return ('a' <= v && v <= 'z') || ('A' <= v && v <= 'Z')
+// isDigitIn reports whether v is a digit of base 2, 10 or 16.
+func isDigitIn(v byte, base int) bool {
+	switch base {
+	case 2:
+		return v == '0' || v == '1'
+	case 16:
+		return isDigit(v) || ('a' <= v && v <= 'f') || ('A' <= v && v <= 'F')
+	}
+	return isDigit(v)
+}
+
+func isDigitOrSeparator(v byte) bool {
+	return isDigit(v) || v == '_'
+}
+
+// isNumberPart accepts what may run on from a number literal: ASCII letters,
+// digits and underscores.
+func isNumberPart(v byte) bool {
+	return isDigitOrSeparator(v) || ('a' <= v && v <= 'z') || ('A' <= v && v <= 'Z')
+}
+
+// isIdentifierStart accepts the first character of an identifier: a
+// letter, in any script, or an underscore.
+func isIdentifierStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
 }
 
-func isAlphanumeric(v byte) bool {
-	return isAlpha(v) || isDigit(v)
+func isIdentifierPart(r rune) bool {
+	return isIdentifierStart(r) || unicode.IsDigit(r)
 }
 
-func isEqual(a, b any) bool {
-	return a == b
+// closestName returns the candidate most similar to name, or "" when none
+// is close enough to be a plausible typo.
+func closestName(name string, candidates []string) string {
+	best, bestDistance := "", len(name)/3+1
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		distance := levenshtein(name, candidate)
+		if distance > bestDistance {
+			continue
+		}
+		// Ties go to the alphabetically first name so the hint is stable.
+		if best == "" || distance < bestDistance || candidate < best {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
 }
 
-func isTruthy(val any) bool {
-	return (val != nil) && (val != false)
+// levenshtein counts the single-character edits needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
 }
@@ -1,163 +1,293 @@
 package internal
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxCallDepth bounds nested calls, so runaway recursion becomes a
+// RuntimeError instead of overflowing the Go stack. It matches the VM, whose
+// first frame is the script itself.
+const maxCallDepth = maxFrames - 1
 
 type Interpreter struct {
-	globalEnv *Environment
-	env       *Environment
+	globalEnv      *Environment
+	env            *Environment
+	locals         map[Expr]int
+	depth          int
+	out            io.Writer
+	tracePrint     bool
+	divisionByZero DivisionByZero
 }
 
 func NewInterpreter() *Interpreter {
-  i := Interpreter{
-		globalEnv: &env,
-		env:       &env,
+	globals := NewEnvironment(nil)
+	i := Interpreter{
+		globalEnv: globals,
+		env:       globals,
+		locals:    make(map[Expr]int),
+	}
+	i.globalEnv.Define("clock", ObjectValue(&GlobalClock{}))
+	return &i
+}
+
+// Resolve records that expr refers to a local variable declared depth scopes
+// out from where it is used. Unresolved expressions are looked up as globals.
+func (i *Interpreter) Resolve(expr Expr, depth int) {
+	i.locals[expr] = depth
+}
+
+func (i *Interpreter) lookUpVariable(name Token, expr Expr) Value {
+	if distance, ok := i.locals[expr]; ok {
+		return i.env.GetAt(distance, name.Lexeme)
+	}
+	if value, ok := i.globalEnv.Variable[name.Lexeme]; ok {
+		return value
 	}
-  i.globalEnv.Define("clock", GlobalClock{})
-  return &i
+	panic(undefinedVariable(name, i.env))
+}
+
+// evaluate computes the value of expr. It switches on the type of expr
+// rather than going through Apply, which would box every intermediate Value
+// into an interface; the Visit methods below only satisfy VisitorExpr.
+func (i *Interpreter) evaluate(expr Expr) Value {
+	switch expr := expr.(type) {
+	case *Literal:
+		return i.literal(expr)
+	case *Grouping:
+		return i.grouping(expr)
+	case *Binary:
+		return i.binary(expr)
+	case *Unary:
+		return i.unary(expr)
+	case *Variable:
+		return i.variable(expr)
+	case *Assignment:
+		return i.assignment(expr)
+	case *Logic:
+		return i.logical(expr)
+	case *Call:
+		return i.call(expr)
+	case *Get:
+		return i.get(expr)
+	case *Set:
+		return i.set(expr)
+	case *This:
+		return i.this(expr)
+	case *Super:
+		return i.super(expr)
+	case *Interpolation:
+		return i.interpolation(expr)
+	}
+	return expr.Apply(i).(Value)
+}
+
+// SetOutput redirects print statements, which go to os.Stdout by default.
+func (i *Interpreter) SetOutput(out io.Writer) {
+	i.out = out
+}
+
+// SetTracePrint prefixes everything print statements write with `>>', which
+// makes program output easy to tell apart from debug dumps.
+func (i *Interpreter) SetTracePrint(enabled bool) {
+	i.tracePrint = enabled
 }
 
-func (i *Interpreter) Interpret(statements []Stmt) {
+// SetDivisionByZero chooses what dividing by zero does. The default is a
+// RuntimeError.
+func (i *Interpreter) SetDivisionByZero(mode DivisionByZero) {
+	i.divisionByZero = mode
+}
+
+// Interpret executes statements in order and stops at the first
+// RuntimeError, which is returned.
+func (i *Interpreter) Interpret(statements []Stmt) (err error) {
+	defer i.recoverRuntimeError(&err)
 	for _, stmt := range statements {
 		stmt.Apply(i)
 	}
+	return nil
 }
 
-func (i *Interpreter) VisitLiteralExpr(expr Literal) any {
-	return expr.Value
+// Evaluate computes the value of a single expression.
+func (i *Interpreter) Evaluate(expr Expr) (result Value, err error) {
+	defer i.recoverRuntimeError(&err)
+	return i.evaluate(expr), nil
 }
 
-func (i *Interpreter) VisitGroupingExpr(expr Grouping) any {
-	return expr.Inside.Apply(i)
+func (i *Interpreter) VisitLiteralExpr(expr *Literal) any {
+	return i.literal(expr)
 }
 
-func (i *Interpreter) VisitBinaryExpr(expr Binary) any {
-	left := expr.Left.Apply(i)
-	right := expr.Right.Apply(i)
-	op := expr.Operator.TokenType
+func (i *Interpreter) VisitGroupingExpr(expr *Grouping) any {
+	return i.grouping(expr)
+}
 
-	switch op {
-	case MINUS:
-		leftVal, leftOk := left.(float64)
-		rightVal, rightOk := right.(float64)
-		if leftOk && rightOk {
-			return leftVal - rightVal
-		}
+func (i *Interpreter) VisitBinaryExpr(expr *Binary) any {
+	return i.binary(expr)
+}
 
-	case STAR:
-		leftVal, leftOk := left.(float64)
-		rightVal, rightOk := right.(float64)
-		if leftOk && rightOk {
-			return leftVal * rightVal
-		}
+func (i *Interpreter) VisitUnaryExpr(expr *Unary) any {
+	return i.unary(expr)
+}
 
-	case SLASH:
-		leftVal, leftOk := left.(float64)
-		rightVal, rightOk := right.(float64)
-		if leftOk && rightOk {
-			return leftVal / rightVal
-		}
+func (i *Interpreter) VisitVariableExpr(expr *Variable) any {
+	return i.variable(expr)
+}
 
-	case PLUS:
-		leftVal, leftOk := left.(float64)
-		rightVal, rightOk := right.(float64)
-		if leftOk && rightOk {
-			return leftVal + rightVal
-		}
+func (i *Interpreter) VisitAssignmentExpr(expr *Assignment) any {
+	return i.assignment(expr)
+}
 
-		leftString, leftStrOk := left.(string)
-		rightString, rightStrOk := right.(string)
-		if leftStrOk && rightStrOk {
-			return leftString + rightString
-		}
+func (i *Interpreter) VisitLogicalExpr(expr *Logic) any {
+	return i.logical(expr)
+}
 
-	case GREATER:
-		leftVal, leftOk := left.(float64)
-		rightVal, rightOk := right.(float64)
-		if leftOk && rightOk {
-			return leftVal > rightVal
-		}
+func (i *Interpreter) VisitCallExpr(expr *Call) any {
+	return i.call(expr)
+}
 
-	case GREATER_EQUAL:
-		leftVal, leftOk := left.(float64)
-		rightVal, rightOk := right.(float64)
-		if leftOk && rightOk {
-			return leftVal >= rightVal
-		}
+func (i *Interpreter) VisitGetExpr(expr *Get) any {
+	return i.get(expr)
+}
+
+func (i *Interpreter) VisitSetExpr(expr *Set) any {
+	return i.set(expr)
+}
+
+func (i *Interpreter) VisitThisExpr(expr *This) any {
+	return i.this(expr)
+}
+
+func (i *Interpreter) VisitSuperExpr(expr *Super) any {
+	return i.super(expr)
+}
 
-	case LESS:
-		leftVal, leftOk := left.(float64)
-		rightVal, rightOk := right.(float64)
-		if leftOk && rightOk {
-			return leftVal < rightVal
+func (i *Interpreter) VisitInterpolationExpr(expr *Interpolation) any {
+	return i.interpolation(expr)
+}
+
+func (i *Interpreter) recoverRuntimeError(err *error) {
+	if r := recover(); r != nil {
+		runtimeErr, ok := r.(*RuntimeError)
+		if !ok {
+			panic(r)
 		}
+		*err = runtimeErr
+	}
+}
 
-	case LESS_EQUAL:
-		leftVal, leftOk := left.(float64)
-		rightVal, rightOk := right.(float64)
-		if leftOk && rightOk {
-			return leftVal <= rightVal
+func (i *Interpreter) literal(expr *Literal) Value {
+	return LiteralValue(expr.Value)
+}
+
+// interpolation joins the segments of the string with its values, each
+// written the way print writes it.
+func (i *Interpreter) interpolation(expr *Interpolation) Value {
+	var sb strings.Builder
+	for n, segment := range expr.Segments {
+		sb.WriteString(segment.Literal.(string))
+		if n < len(expr.Expressions) {
+			sb.WriteString(i.evaluate(expr.Expressions[n]).String())
 		}
+	}
+	return StringValue(sb.String())
+}
+
+func (i *Interpreter) grouping(expr *Grouping) Value {
+	return i.evaluate(expr.Inside)
+}
+
+func (i *Interpreter) binary(expr *Binary) Value {
+	left := i.evaluate(expr.Left)
+	right := i.evaluate(expr.Right)
+
+	switch expr.Operator.TokenType {
 	case EQUAL_EQUAL:
-		return isEqual(left, right)
+		return BoolValue(left.Equal(right))
 	case BANG_EQUAL:
-		return !isEqual(left, right)
+		return BoolValue(!left.Equal(right))
 	}
-	return nil
+	result, message := binaryOperation(expr.Operator.TokenType, left, right, i.divisionByZero)
+	if message != "" {
+		panic(&RuntimeError{Token: expr.Operator, Message: message})
+	}
+	return result
 }
 
-func (i *Interpreter) VisitUnaryExpr(expr Unary) any {
-	op := expr.Operator.TokenType
-	right := expr.Right.Apply(i)
-	switch op {
-	case MINUS:
-		rightVal, ok := right.(float64)
-		if ok {
-			return -rightVal
-		}
-	case BANG:
-		return !isTruthy(right)
+func (i *Interpreter) unary(expr *Unary) Value {
+	right := i.evaluate(expr.Right)
+	if expr.Operator.TokenType == BANG {
+		return BoolValue(!right.Truthy())
 	}
-	return nil
+	result, message := negate(right)
+	if message != "" {
+		panic(&RuntimeError{Token: expr.Operator, Message: message})
+	}
+	return result
 }
 
-func (i *Interpreter) VisitVariableExpr(expr Variable) any {
-	return i.env.Get(expr.Name.Lexeme)
+func (i *Interpreter) variable(expr *Variable) Value {
+	return i.lookUpVariable(expr.Name, expr)
 }
 
 func (i *Interpreter) VisitExpression(stmt Expression) any {
-	return stmt.Expr.Apply(i)
+	i.evaluate(stmt.Expr)
+	return nil
 }
 
 func (i *Interpreter) VisitPrint(stmt Print) any {
-	val := stmt.Expr.Apply(i)
-	fmt.Println(">>", val)
+	val := i.evaluate(stmt.Expr)
+	out := i.out
+	if out == nil {
+		out = os.Stdout
+	}
+	if i.tracePrint {
+		fmt.Fprintln(out, ">>", val)
+	} else {
+		fmt.Fprintln(out, val)
+	}
 	return val
 }
 
 func (i *Interpreter) VisitVarDeclare(stmt VarDeclare) any {
-	var value any
+	var value Value
 	if stmt.InitialExpr != nil {
-		value = stmt.InitialExpr.Apply(i)
+		value = i.evaluate(stmt.InitialExpr)
 	}
-	i.env.Define(stmt.Name, value)
+	i.env.Define(stmt.Name.Lexeme, value)
 	return nil
 }
 
-func (i *Interpreter) VisitAssignmentExpr(expr Assignment) any {
-	value := expr.Value.Apply(i)
-	i.env.Assign(expr.Name.Lexeme, value)
-	return nil
+func (i *Interpreter) assignment(expr *Assignment) Value {
+	value := i.evaluate(expr.Value)
+	if distance, ok := i.locals[expr]; ok {
+		i.env.AssignAt(distance, expr.Name, value)
+	} else if _, ok := i.globalEnv.Variable[expr.Name.Lexeme]; ok {
+		i.globalEnv.Variable[expr.Name.Lexeme] = value
+	} else {
+		panic(undefinedVariable(expr.Name, i.env))
+	}
+	return value
 }
 
 func (i *Interpreter) VisitBlock(stmt Block) any {
+	return i.ExecuteBlock(stmt.Statements, NewEnvironment(i.env))
+}
+
+// ExecuteBlock runs statements inside env and restores the current
+// environment afterwards, even when a return or runtime error unwinds it.
+func (i *Interpreter) ExecuteBlock(statements []Stmt, env *Environment) any {
 	upperEnv := i.env
 	defer func() {
 		i.env = upperEnv
 	}()
-	i.env = NewEnvironment(*upperEnv)
+	i.env = env
 
 	var output any
-	for _, statement := range stmt.Statements {
+	for _, statement := range statements {
 		output = statement.Apply(i)
 	}
 	return output
@@ -165,65 +295,150 @@ func (i *Interpreter) VisitBlock(stmt Block) any {
 
 func (i *Interpreter) VisitIfStmt(stmt IfStmt) any {
 	var output any
-	if isTruthy(stmt.Condition.Apply(i)) {
+	if i.evaluate(stmt.Condition).Truthy() {
 		output = stmt.ThenBranch.Apply(i)
-	} else {
+	} else if stmt.ElseBranch != nil {
 		output = stmt.ElseBranch.Apply(i)
 	}
 	return output
 }
 
-func (i *Interpreter) VisitLogicalExpr(expr Logic) any {
-	left := expr.Left.Apply(i)
+func (i *Interpreter) logical(expr *Logic) Value {
+	left := i.evaluate(expr.Left)
 	op := expr.Operator.TokenType
 	switch op {
 	case OR:
-		if isTruthy(left) {
-			return true
+		if left.Truthy() {
+			return BoolValue(true)
 		}
 	case AND:
-		if !isTruthy(left) {
-			return false
+		if !left.Truthy() {
+			return BoolValue(false)
 		}
 	}
-	right := expr.Right.Apply(i)
-	return isTruthy(right)
+	right := i.evaluate(expr.Right)
+	return BoolValue(right.Truthy())
 }
 
 func (i *Interpreter) VisitWhileStmt(expr WhileStmt) any {
-	cond := expr.Condition.Apply(i)
-	for isTruthy(cond) {
+	for i.evaluate(expr.Condition).Truthy() {
 		expr.Body.Apply(i)
-		cond = expr.Condition.Apply(i)
 	}
 	return nil
 }
 
-func (i *Interpreter) VisitCallExpr(expr Call) any {
-	callee := expr.Callee.Apply(i)
+func (i *Interpreter) call(expr *Call) Value {
+	callee := i.evaluate(expr.Callee)
 
-	args := []any{}
+	args := make([]Value, 0, len(expr.Arguments))
 	for _, arg := range expr.Arguments {
-	  args = append(args, arg)
-	}
-	callable, ok := callee.(LoxCallable)
-  if !ok {
-    fmt.Println("Not a function", callable)
-    panic("Trying to call not a function")
-  } 
-  if callable.Arity() != len(args) {
-    panic(fmt.Sprintf("Runtime error: Expected %d arguments but got %d arguments", callable.Arity(), len(args)))
-  }
-
-  callable.Call(i, &args)
-	return nil
+		args = append(args, i.evaluate(arg))
+	}
+	callable, ok := callee.AsObject().(LoxCallable)
+	if !ok {
+		panic(&RuntimeError{Token: expr.paren, Message: "Can only call functions and classes."})
+	}
+	if callable.Arity() != len(args) {
+		panic(&RuntimeError{Token: expr.paren, Message: arityMessage(callable, callable.Arity(), len(args))})
+	}
+	if i.depth == maxCallDepth {
+		panic(&RuntimeError{Token: expr.paren, Message: "Stack overflow."})
+	}
+
+	i.depth++
+	defer func() { i.depth-- }()
+	return callable.Call(i, &args)
 }
 
 func (i *Interpreter) VisitFunctionStmt(stmt FunctionStmt) any {
-  function := &LoxFunction{
-    Declaration: stmt,
-    Closure: i.globalEnv,
-  }
-  i.env.Define(stmt.Name.Lexeme, function)
-  return nil
+	function := &LoxFunction{
+		Declaration: stmt,
+		Closure:     i.env,
+	}
+	i.env.Define(stmt.Name.Lexeme, ObjectValue(function))
+	return nil
+}
+
+func (i *Interpreter) VisitReturnStmt(stmt ReturnStmt) any {
+	var value Value
+	if stmt.Value != nil {
+		value = i.evaluate(stmt.Value)
+	}
+	panic(&returnValue{value})
+}
+
+func (i *Interpreter) VisitClassStmt(stmt ClassStmt) any {
+	var superclass *LoxClass
+	if stmt.Superclass != nil {
+		class, ok := i.evaluate(stmt.Superclass).AsObject().(*LoxClass)
+		if !ok {
+			panic(&RuntimeError{Token: stmt.Superclass.Name, Message: "Superclass must be a class."})
+		}
+		superclass = class
+	}
+
+	i.env.Define(stmt.Name.Lexeme, NilValue())
+
+	if superclass != nil {
+		i.env = NewEnvironment(i.env)
+		i.env.Define("super", ObjectValue(superclass))
+	}
+
+	methods := make(map[string]*LoxFunction)
+	for _, method := range stmt.Methods {
+		methods[method.Name.Lexeme] = &LoxFunction{
+			Declaration:   *method,
+			Closure:       i.env,
+			IsInitializer: method.Name.Lexeme == "init",
+		}
+	}
+
+	class := NewLoxClass(stmt.Name.Lexeme, superclass, methods)
+	if superclass != nil {
+		i.env = i.env.Enclosing
+	}
+	i.env.Assign(stmt.Name, ObjectValue(class))
+	return nil
+}
+
+func (i *Interpreter) get(expr *Get) Value {
+	object := i.evaluate(expr.Object)
+	if instance, ok := object.AsObject().(*LoxInstance); ok {
+		return instance.Get(expr.Name)
+	}
+	panic(&RuntimeError{Token: expr.Name, Message: "Only instances have properties."})
+}
+
+func (i *Interpreter) set(expr *Set) Value {
+	object := i.evaluate(expr.Object)
+	instance, ok := object.AsObject().(*LoxInstance)
+	if !ok {
+		panic(&RuntimeError{Token: expr.Name, Message: "Only instances have fields."})
+	}
+
+	value := i.evaluate(expr.Value)
+	instance.Set(expr.Name, value)
+	return value
+}
+
+func (i *Interpreter) this(expr *This) Value {
+	return i.lookUpVariable(expr.Keyword, expr)
+}
+
+// VisitSuperExpr finds the method on the superclass captured when the class
+// was declared and binds it to the `this' of the current method call.
+func (i *Interpreter) super(expr *Super) Value {
+	distance := i.locals[expr]
+	superclass := i.env.GetAt(distance, "super").AsObject().(*LoxClass)
+	object := i.env.GetAt(distance-1, "this").AsObject().(*LoxInstance)
+
+	method := superclass.FindMethod(expr.Method.Lexeme)
+	if method == nil {
+		panic(&RuntimeError{
+			Token:      expr.Method,
+			Message:    "Undefined property '" + expr.Method.Lexeme + "'.",
+			Suggestion: closestName(expr.Method.Lexeme, superclass.MethodNames()),
+		})
+	}
+	return ObjectValue(method.Bind(object))
 }
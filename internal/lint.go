@@ -0,0 +1,437 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// LintRule is one check of the Linter. Check looks at the program through
+// the pass and reports what it finds with pass.Report.
+type LintRule struct {
+	Name        string
+	Description string
+	Check       func(pass *LintPass)
+}
+
+// LintRules are all the rules the Linter knows, enabled by default.
+var LintRules = []LintRule{
+	{"unused-variable", "a variable is declared but its value is never read", lintUnusedVariables},
+	{"unreachable-code", "a statement follows a return in the same block", lintUnreachableCode},
+	{"assign-in-condition", "an if or loop condition is an assignment, probably meant as ==", lintAssignInCondition},
+	{"literal-type-comparison", "literals of different types are compared, which is always false", lintLiteralTypeComparison},
+	{"shadowing", "a declaration hides a variable of an enclosing scope", lintShadowing},
+	{"empty-block", "a block has no statements and no comment explaining why", lintEmptyBlock},
+}
+
+// LintConfig turns rules on and off. Rules missing from Rules stay enabled.
+// It is read from JSON such as
+//
+//	{"rules": {"shadowing": false}}
+type LintConfig struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// LoadLintConfig reads a LintConfig and rejects rule names the Linter does
+// not know, so a typo does not silently leave a rule on.
+func LoadLintConfig(path string) (LintConfig, error) {
+	var config LintConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	for name := range config.Rules {
+		if lintRule(name) == nil {
+			return config, fmt.Errorf("%s: unknown lint rule %q", path, name)
+		}
+	}
+	return config, nil
+}
+
+func (c LintConfig) Enabled(rule string) bool {
+	enabled, set := c.Rules[rule]
+	return !set || enabled
+}
+
+func lintRule(name string) *LintRule {
+	for n := range LintRules {
+		if LintRules[n].Name == name {
+			return &LintRules[n]
+		}
+	}
+	return nil
+}
+
+// LintPass is what a rule gets to inspect: the parsed program, its symbols
+// and its comments.
+type LintPass struct {
+	Source     []byte
+	Statements []Stmt
+	Symbols    *SymbolTable
+	Comments   []Token
+
+	rule     string
+	warnings []error
+}
+
+func (p *LintPass) Report(span Span, message string) {
+	p.warnings = append(p.warnings, &LintWarning{Rule: p.rule, Span: span, Message: message})
+}
+
+// Walk calls visit for every statement and expression of the program,
+// parents before children.
+func (p *LintPass) Walk(visit func(node any)) {
+	walker := astWalker{visit}
+	for _, stmt := range p.Statements {
+		walker.stmt(stmt)
+	}
+}
+
+// Lint checks source with the rules enabled in config. Scan and parse errors
+// are returned instead of warnings, since the rules need a complete tree.
+// Warnings come sorted by position, without those silenced by a
+// `// golox:ignore rule' comment on the same or the previous line.
+func Lint(source []byte, config LintConfig) []error {
+	scanner := NewScanner(source)
+	tokens, scanErrors := scanner.ScanTokens()
+	parser := NewParser(tokens)
+	statements, parseErrors := parser.Parse()
+	if errs := append(scanErrors, parseErrors...); len(errs) > 0 {
+		return errs
+	}
+
+	pass := &LintPass{
+		Source:     source,
+		Statements: statements,
+		Symbols:    NewSymbolTable(statements),
+		Comments:   scanner.Comments,
+	}
+	for _, rule := range LintRules {
+		if config.Enabled(rule.Name) {
+			pass.rule = rule.Name
+			rule.Check(pass)
+		}
+	}
+
+	ignored := lintIgnores(scanner.Comments)
+	warnings := []error{}
+	for _, warning := range pass.warnings {
+		warning := warning.(*LintWarning)
+		if !ignored[warning.Span.Start.Line][warning.Rule] && !ignored[warning.Span.Start.Line][""] {
+			warnings = append(warnings, warning)
+		}
+	}
+	sort.SliceStable(warnings, func(a, b int) bool {
+		return warnings[a].(*LintWarning).Span.Start.Offset < warnings[b].(*LintWarning).Span.Start.Offset
+	})
+	return warnings
+}
+
+// lintIgnores maps each line to the rules silenced on it. A directive covers
+// its own line, for trailing comments, and the line after it. A directive
+// without rule names silences every rule, recorded as "".
+func lintIgnores(comments []Token) map[int]map[string]bool {
+	ignored := make(map[int]map[string]bool)
+	for _, comment := range comments {
+		text := strings.TrimPrefix(comment.Lexeme, "//")
+		if block, found := strings.CutPrefix(comment.Lexeme, "/*"); found {
+			text = strings.TrimSuffix(block, "*/")
+		}
+		text = strings.TrimSpace(text)
+		rules, found := strings.CutPrefix(text, "golox:ignore")
+		if !found || (rules != "" && rules[0] != ' ') {
+			continue
+		}
+		names := strings.FieldsFunc(rules, func(r rune) bool { return r == ' ' || r == ',' })
+		if len(names) == 0 {
+			names = []string{""}
+		}
+		for _, line := range []int{comment.Line, comment.Span().End.Line + 1} {
+			if ignored[line] == nil {
+				ignored[line] = make(map[string]bool)
+			}
+			for _, name := range names {
+				ignored[line][name] = true
+			}
+		}
+	}
+	return ignored
+}
+
+// rules
+
+func lintUnusedVariables(pass *LintPass) {
+	for _, symbol := range pass.Symbols.Symbols {
+		if symbol.Kind == SymbolVariable && symbol.Reads == 0 {
+			pass.Report(symbol.Token.Span(), fmt.Sprintf("Variable '%s' is never read.", symbol.Name))
+		}
+	}
+}
+
+func lintUnreachableCode(pass *LintPass) {
+	check := func(statements []Stmt) {
+		for n, stmt := range statements[:max(len(statements)-1, 0)] {
+			if _, ok := stmt.(ReturnStmt); ok {
+				unreachable := SpanBetween(statements[n+1].Span(), statements[len(statements)-1].Span())
+				pass.Report(unreachable, "Unreachable code after 'return'.")
+				return
+			}
+		}
+	}
+	check(pass.Statements)
+	pass.Walk(func(node any) {
+		switch node := node.(type) {
+		case Block:
+			if _, written := writtenBlock(pass.Source, node); written {
+				check(node.Statements)
+			}
+		case FunctionStmt:
+			check(node.Body)
+		}
+	})
+}
+
+// lintAssignInCondition only looks at the condition itself: an assignment
+// in parentheses, as in `while ((line = next()))', is taken as deliberate.
+func lintAssignInCondition(pass *LintPass) {
+	check := func(condition Expr) {
+		switch condition := condition.(type) {
+		case *Assignment, *Set:
+			pass.Report(condition.Span(), "Assignment used as a condition; did you mean '=='?")
+		}
+	}
+	pass.Walk(func(node any) {
+		switch node := node.(type) {
+		case IfStmt:
+			check(node.Condition)
+		case WhileStmt:
+			check(node.Condition)
+		}
+	})
+}
+
+func lintLiteralTypeComparison(pass *LintPass) {
+	pass.Walk(func(node any) {
+		binary, ok := node.(*Binary)
+		if !ok || (binary.Operator.TokenType != EQUAL_EQUAL && binary.Operator.TokenType != BANG_EQUAL) {
+			return
+		}
+		left, leftIsLiteral := binary.Left.(*Literal)
+		right, rightIsLiteral := binary.Right.(*Literal)
+		if !leftIsLiteral || !rightIsLiteral {
+			return
+		}
+		leftType, rightType := literalType(left.Value), literalType(right.Value)
+		if leftType != rightType {
+			result := "false"
+			if binary.Operator.TokenType == BANG_EQUAL {
+				result = "true"
+			}
+			pass.Report(binary.Span(), fmt.Sprintf("Comparing a %s with a %s is always %s.", leftType, rightType, result))
+		}
+	})
+}
+
+func literalType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case int64, float64:
+		return "number"
+	case string:
+		return "string"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func lintShadowing(pass *LintPass) {
+	for _, symbol := range pass.Symbols.Symbols {
+		if symbol.IsGlobal() || symbol.Kind == SymbolMethod {
+			continue
+		}
+		for _, outer := range pass.Symbols.Visible(symbol.Token.Offset) {
+			if outer.Name == symbol.Name && outer.Scope != symbol.Scope {
+				pass.Report(symbol.Token.Span(), fmt.Sprintf("'%s' shadows the %s declared at %d:%d.",
+					symbol.Name, outer.Kind, outer.Token.Line, outer.Token.Column))
+				break
+			}
+		}
+	}
+}
+
+func lintEmptyBlock(pass *LintPass) {
+	pass.Walk(func(node any) {
+		block, ok := node.(Block)
+		if !ok || len(block.Statements) > 0 {
+			return
+		}
+		for _, comment := range pass.Comments {
+			if block.Range.Contains(comment.Offset) {
+				return
+			}
+		}
+		pass.Report(block.Range, "Empty block.")
+	})
+}
+
+// astWalker visits a whole tree for LintPass.Walk.
+type astWalker struct {
+	visit func(node any)
+}
+
+func (w astWalker) stmt(stmt Stmt) {
+	switch stmt := stmt.(type) {
+	case *FunctionStmt:
+		w.stmt(*stmt)
+	case *WhileStmt:
+		w.stmt(*stmt)
+	default:
+		w.visit(stmt)
+		stmt.Apply(w)
+	}
+}
+
+func (w astWalker) expr(expr Expr) {
+	if expr != nil {
+		w.visit(expr)
+		expr.Apply(w)
+	}
+}
+
+func (w astWalker) statements(statements []Stmt) {
+	for _, stmt := range statements {
+		w.stmt(stmt)
+	}
+}
+
+func (w astWalker) VisitExpression(stmt Expression) any {
+	w.expr(stmt.Expr)
+	return nil
+}
+
+func (w astWalker) VisitPrint(stmt Print) any {
+	w.expr(stmt.Expr)
+	return nil
+}
+
+func (w astWalker) VisitVarDeclare(stmt VarDeclare) any {
+	w.expr(stmt.InitialExpr)
+	return nil
+}
+
+func (w astWalker) VisitBlock(stmt Block) any {
+	w.statements(stmt.Statements)
+	return nil
+}
+
+func (w astWalker) VisitIfStmt(stmt IfStmt) any {
+	w.expr(stmt.Condition)
+	w.stmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		w.stmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (w astWalker) VisitWhileStmt(stmt WhileStmt) any {
+	w.expr(stmt.Condition)
+	w.stmt(stmt.Body)
+	return nil
+}
+
+func (w astWalker) VisitFunctionStmt(stmt FunctionStmt) any {
+	w.statements(stmt.Body)
+	return nil
+}
+
+func (w astWalker) VisitReturnStmt(stmt ReturnStmt) any {
+	w.expr(stmt.Value)
+	return nil
+}
+
+func (w astWalker) VisitClassStmt(stmt ClassStmt) any {
+	if stmt.Superclass != nil {
+		w.expr(stmt.Superclass)
+	}
+	for _, method := range stmt.Methods {
+		w.stmt(*method)
+	}
+	return nil
+}
+
+func (w astWalker) VisitBinaryExpr(expr *Binary) any {
+	w.expr(expr.Left)
+	w.expr(expr.Right)
+	return nil
+}
+
+func (w astWalker) VisitUnaryExpr(expr *Unary) any {
+	w.expr(expr.Right)
+	return nil
+}
+
+func (w astWalker) VisitLiteralExpr(expr *Literal) any {
+	return nil
+}
+
+func (w astWalker) VisitGroupingExpr(expr *Grouping) any {
+	w.expr(expr.Inside)
+	return nil
+}
+
+func (w astWalker) VisitVariableExpr(expr *Variable) any {
+	return nil
+}
+
+func (w astWalker) VisitAssignmentExpr(expr *Assignment) any {
+	w.expr(expr.Value)
+	return nil
+}
+
+func (w astWalker) VisitCallExpr(expr *Call) any {
+	w.expr(expr.Callee)
+	for _, arg := range expr.Arguments {
+		w.expr(arg)
+	}
+	return nil
+}
+
+func (w astWalker) VisitInterpolationExpr(expr *Interpolation) any {
+	for _, part := range expr.Expressions {
+		w.expr(part)
+	}
+	return nil
+}
+
+func (w astWalker) VisitLogicalExpr(expr *Logic) any {
+	w.expr(expr.Left)
+	w.expr(expr.Right)
+	return nil
+}
+
+func (w astWalker) VisitGetExpr(expr *Get) any {
+	w.expr(expr.Object)
+	return nil
+}
+
+func (w astWalker) VisitSetExpr(expr *Set) any {
+	w.expr(expr.Object)
+	w.expr(expr.Value)
+	return nil
+}
+
+func (w astWalker) VisitThisExpr(expr *This) any {
+	return nil
+}
+
+func (w astWalker) VisitSuperExpr(expr *Super) any {
+	return nil
+}
@@ -1,16 +1,29 @@
 package internal
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type LoxCallable interface {
-	Call(i *Interpreter, arguments *[]any) any
+	Call(i *Interpreter, arguments *[]Value) Value
 	Arity() int
 }
 
+// arityMessage is the RuntimeError message for calling callee, which takes
+// arity arguments, with argc of them. Both backends use it.
+func arityMessage(callee any, arity, argc int) string {
+	if arity == 1 {
+		return fmt.Sprintf("%v expected 1 argument but got %d.", callee, argc)
+	}
+	return fmt.Sprintf("%v expected %d arguments but got %d.", callee, arity, argc)
+}
+
 type GlobalClock struct{}
 
-func (c *GlobalClock) Call(i *Interpreter, arguments *[]any) any {
-	return time.Now()
+// Call returns the number of seconds since the Unix epoch.
+func (c *GlobalClock) Call(i *Interpreter, arguments *[]Value) Value {
+	return FloatValue(float64(time.Now().UnixNano()) / float64(time.Second))
 }
 
 func (c *GlobalClock) Arity() int {
@@ -18,7 +31,5 @@ func (c *GlobalClock) Arity() int {
 }
 
 func (c *GlobalClock) String() string {
-  return "<native fn>"
+	return "<native fn>"
 }
-
-
@@ -0,0 +1,58 @@
+package internal
+
+type LoxClass struct {
+	Name       string
+	Superclass *LoxClass
+	Methods    map[string]*LoxFunction
+}
+
+func NewLoxClass(name string, superclass *LoxClass, methods map[string]*LoxFunction) *LoxClass {
+	return &LoxClass{
+		Name:       name,
+		Superclass: superclass,
+		Methods:    methods,
+	}
+}
+
+// FindMethod looks name up on the class and then along its superclass chain.
+func (c *LoxClass) FindMethod(name string) *LoxFunction {
+	if method, ok := c.Methods[name]; ok {
+		return method
+	}
+	if c.Superclass != nil {
+		return c.Superclass.FindMethod(name)
+	}
+	return nil
+}
+
+// MethodNames lists the methods of the class and its superclasses.
+func (c *LoxClass) MethodNames() []string {
+	names := []string{}
+	for class := c; class != nil; class = class.Superclass {
+		for name := range class.Methods {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Call constructs a new instance and runs its `init' method, if any, with
+// the arguments given to the class.
+func (c *LoxClass) Call(i *Interpreter, args *[]Value) Value {
+	instance := NewLoxInstance(c)
+	if initializer := c.FindMethod("init"); initializer != nil {
+		initializer.Bind(instance).Call(i, args)
+	}
+	return ObjectValue(instance)
+}
+
+func (c *LoxClass) Arity() int {
+	if initializer := c.FindMethod("init"); initializer != nil {
+		return initializer.Arity()
+	}
+	return 0
+}
+
+func (c *LoxClass) String() string {
+	return c.Name
+}
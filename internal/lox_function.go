@@ -1,8 +1,11 @@
 package internal
 
+import "fmt"
+
 type LoxFunction struct {
-	Declaration FunctionStmt
-	Closure     *Environment
+	Declaration   FunctionStmt
+	Closure       *Environment
+	IsInitializer bool
 }
 
 func NewLoxFunction(declaration FunctionStmt) LoxFunction {
@@ -11,15 +14,52 @@ func NewLoxFunction(declaration FunctionStmt) LoxFunction {
 	}
 }
 
-func (lx *LoxFunction) Call(i *Interpreter, args *[]any) any {
-	env := i.globalEnv
+// returnValue carries the value of a `return` statement up the Go stack,
+// through any enclosing blocks and loops, to the LoxFunction being called.
+type returnValue struct {
+	Value Value
+}
+
+func (lx *LoxFunction) Call(i *Interpreter, args *[]Value) (result Value) {
+	env := NewEnvironment(lx.Closure)
 	for j := 0; j < len(lx.Declaration.Params); j++ {
 		env.Define(lx.Declaration.Params[j].Lexeme, (*args)[j])
 	}
-	lx.Declaration.Body.Apply(i)
-  return nil
+
+	defer func() {
+		if r := recover(); r != nil {
+			ret, ok := r.(*returnValue)
+			if !ok {
+				panic(r)
+			}
+			result = ret.Value
+			if lx.IsInitializer {
+				result = lx.Closure.GetAt(0, "this")
+			}
+		}
+	}()
+	i.ExecuteBlock(lx.Declaration.Body, env)
+	if lx.IsInitializer {
+		return lx.Closure.GetAt(0, "this")
+	}
+	return NilValue()
+}
+
+// Bind returns a copy of the method whose closure defines `this' as instance.
+func (lx *LoxFunction) Bind(instance *LoxInstance) *LoxFunction {
+	env := NewEnvironment(lx.Closure)
+	env.Define("this", ObjectValue(instance))
+	return &LoxFunction{
+		Declaration:   lx.Declaration,
+		Closure:       env,
+		IsInitializer: lx.IsInitializer,
+	}
 }
 
 func (lx *LoxFunction) Arity() int {
-  return len(lx.Declaration.Params)
+	return len(lx.Declaration.Params)
+}
+
+func (lx *LoxFunction) String() string {
+	return fmt.Sprintf("<fn %s>", lx.Declaration.Name.Lexeme)
 }
@@ -0,0 +1,43 @@
+package internal
+
+type LoxInstance struct {
+	Class  *LoxClass
+	Fields map[string]Value
+}
+
+func NewLoxInstance(class *LoxClass) *LoxInstance {
+	return &LoxInstance{
+		Class:  class,
+		Fields: make(map[string]Value),
+	}
+}
+
+// Get looks up a field first, so fields shadow methods, and otherwise returns
+// the class method bound to this instance.
+func (instance *LoxInstance) Get(name Token) Value {
+	if value, ok := instance.Fields[name.Lexeme]; ok {
+		return value
+	}
+
+	if method := instance.Class.FindMethod(name.Lexeme); method != nil {
+		return ObjectValue(method.Bind(instance))
+	}
+
+	candidates := instance.Class.MethodNames()
+	for field := range instance.Fields {
+		candidates = append(candidates, field)
+	}
+	panic(&RuntimeError{
+		Token:      name,
+		Message:    "Undefined property '" + name.Lexeme + "'.",
+		Suggestion: closestName(name.Lexeme, candidates),
+	})
+}
+
+func (instance *LoxInstance) Set(name Token, value Value) {
+	instance.Fields[name.Lexeme] = value
+}
+
+func (instance *LoxInstance) String() string {
+	return instance.Class.Name + " instance"
+}
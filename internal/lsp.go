@@ -0,0 +1,504 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// JSON-RPC error codes used by the LanguageServer.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// LanguageServer speaks the Language Server Protocol over a pair of streams,
+// normally stdin and stdout. Documents are kept in full and re-analysed on
+// every change; Lox files are small enough for that to be instant.
+type LanguageServer struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*lspDocument
+	shutdown  bool
+}
+
+func NewLanguageServer(in io.Reader, out io.Writer) *LanguageServer {
+	return &LanguageServer{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: make(map[string]*lspDocument),
+	}
+}
+
+// Serve handles messages until the client sends `exit' or closes the input.
+// It reports an error when the session did not end with `shutdown' followed
+// by `exit'.
+func (s *LanguageServer) Serve() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return errors.New("lsp: input closed before exit")
+		} else if err != nil {
+			return err
+		}
+
+		var message rpcMessage
+		if err := json.Unmarshal(body, &message); err != nil {
+			null := json.RawMessage("null")
+			s.send(rpcMessage{ID: &null, Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			continue
+		}
+		if message.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("lsp: exit before shutdown")
+			}
+			return nil
+		}
+		s.handle(message)
+	}
+}
+
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// readMessage reads one `Content-Length' framed message body.
+func readMessage(in *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length < 0 {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("lsp: reading header: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("lsp: bad Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("lsp: message without Content-Length")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(in, body); err != nil {
+		return nil, fmt.Errorf("lsp: reading body: %w", err)
+	}
+	return body, nil
+}
+
+func (s *LanguageServer) send(message rpcMessage) {
+	message.JSONRPC = "2.0"
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *LanguageServer) notify(method string, params any) {
+	data, _ := json.Marshal(params)
+	s.send(rpcMessage{Method: method, Params: data})
+}
+
+// handle dispatches one request or notification. Requests always get a
+// response; a nil result is sent as JSON null.
+func (s *LanguageServer) handle(message rpcMessage) {
+	var result any
+	var err *rpcError
+	switch {
+	case s.shutdown && message.ID != nil:
+		err = &rpcError{Code: rpcInvalidRequest, Message: "server is shutting down"}
+	case message.Method == "initialize":
+		result = s.initialize()
+	case message.Method == "shutdown":
+		s.shutdown = true
+	case message.Method == "textDocument/didOpen":
+		var params struct{ TextDocument lspTextDocumentItem }
+		if err = decodeParams(message.Params, &params); err == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case message.Method == "textDocument/didChange":
+		var params struct {
+			TextDocument   lspTextDocumentIdentifier
+			ContentChanges []struct{ Text string }
+		}
+		if err = decodeParams(message.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case message.Method == "textDocument/didClose":
+		var params struct{ TextDocument lspTextDocumentIdentifier }
+		if err = decodeParams(message.Params, &params); err == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", lspPublishDiagnostics{URI: params.TextDocument.URI, Diagnostics: []lspDiagnostic{}})
+		}
+	case message.Method == "textDocument/definition":
+		result, err = s.withPosition(message.Params, s.definition)
+	case message.Method == "textDocument/references":
+		result, err = s.withPosition(message.Params, s.references)
+	case message.Method == "textDocument/hover":
+		result, err = s.withPosition(message.Params, s.hover)
+	case message.Method == "textDocument/completion":
+		result, err = s.withPosition(message.Params, s.completion)
+	case message.Method == "textDocument/documentSymbol":
+		var params struct{ TextDocument lspTextDocumentIdentifier }
+		if err = decodeParams(message.Params, &params); err == nil {
+			result, err = s.documentSymbols(params.TextDocument.URI)
+		}
+	case message.ID != nil:
+		err = &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method %q is not supported", message.Method)}
+	}
+
+	if message.ID == nil {
+		return
+	}
+	if err != nil {
+		s.send(rpcMessage{ID: message.ID, Error: err})
+		return
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	s.send(rpcMessage{ID: message.ID, Result: result})
+}
+
+func decodeParams(params json.RawMessage, into any) *rpcError {
+	if err := json.Unmarshal(params, into); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *LanguageServer) initialize() any {
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync":       1, // full document on every change
+			"definitionProvider":     true,
+			"referencesProvider":     true,
+			"hoverProvider":          true,
+			"documentSymbolProvider": true,
+			"completionProvider":     map[string]any{},
+		},
+		"serverInfo": map[string]any{"name": "golox"},
+	}
+}
+
+// update re-analyses a document and publishes its diagnostics.
+func (s *LanguageServer) update(uri, text string) {
+	document := newLspDocument(uri, text)
+	s.documents[uri] = document
+
+	diagnostics := make([]lspDiagnostic, len(document.errors))
+	for n, err := range document.errors {
+		d := NewDiagnostic(err)
+		diagnostics[n] = lspDiagnostic{
+			Range:    document.lspRange(d.Span),
+			Severity: lspSeverity(d.Severity),
+			Code:     d.Code,
+			Source:   "golox",
+			Message:  d.Message,
+		}
+		if d.Suggestion != "" {
+			diagnostics[n].Message += fmt.Sprintf(" Did you mean '%s'?", d.Suggestion)
+		}
+		for _, note := range d.Notes {
+			if note.Span == (Span{}) {
+				diagnostics[n].Message += "\n" + note.Message
+				continue
+			}
+			diagnostics[n].RelatedInformation = append(diagnostics[n].RelatedInformation, lspRelatedInformation{
+				Location: lspLocation{URI: uri, Range: document.lspRange(note.Span)},
+				Message:  note.Message,
+			})
+		}
+	}
+	s.notify("textDocument/publishDiagnostics", lspPublishDiagnostics{URI: uri, Diagnostics: diagnostics})
+}
+
+// withPosition decodes TextDocumentPositionParams and calls handler with
+// the document and the byte offset of the position.
+func (s *LanguageServer) withPosition(params json.RawMessage, handler func(*lspDocument, int, json.RawMessage) any) (any, *rpcError) {
+	var position struct {
+		TextDocument lspTextDocumentIdentifier
+		Position     lspPosition
+	}
+	if err := decodeParams(params, &position); err != nil {
+		return nil, err
+	}
+	document, ok := s.documents[position.TextDocument.URI]
+	if !ok {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("document %q is not open", position.TextDocument.URI)}
+	}
+	return handler(document, document.offset(position.Position), params), nil
+}
+
+func (s *LanguageServer) definition(document *lspDocument, offset int, _ json.RawMessage) any {
+	symbol := document.symbols.SymbolAt(offset)
+	if symbol == nil {
+		return nil
+	}
+	return lspLocation{URI: document.uri, Range: document.lspRange(symbol.Token.Span())}
+}
+
+func (s *LanguageServer) references(document *lspDocument, offset int, params json.RawMessage) any {
+	var context struct {
+		Context struct{ IncludeDeclaration bool }
+	}
+	json.Unmarshal(params, &context)
+
+	locations := []lspLocation{}
+	symbol := document.symbols.SymbolAt(offset)
+	if symbol == nil {
+		return locations
+	}
+	if context.Context.IncludeDeclaration {
+		locations = append(locations, lspLocation{URI: document.uri, Range: document.lspRange(symbol.Token.Span())})
+	}
+	for _, reference := range symbol.References {
+		locations = append(locations, lspLocation{URI: document.uri, Range: document.lspRange(reference.Span())})
+	}
+	return locations
+}
+
+func (s *LanguageServer) hover(document *lspDocument, offset int, _ json.RawMessage) any {
+	symbol := document.symbols.SymbolAt(offset)
+	if symbol == nil {
+		return nil
+	}
+	span := symbol.Token.Span()
+	for _, reference := range symbol.References {
+		if reference.Offset <= offset && offset <= reference.Offset+reference.Length {
+			span = reference.Span()
+		}
+	}
+	value := fmt.Sprintf("(%s) %s", symbol.Kind, symbol.Detail)
+	if symbol.Doc != "" {
+		value += "\n\n" + symbol.Doc
+	}
+	return map[string]any{
+		"contents": map[string]any{
+			"kind":  "plaintext",
+			"value": value,
+		},
+		"range": document.lspRange(span),
+	}
+}
+
+// completion offers the names in scope at the cursor, innermost first,
+// followed by every keyword.
+func (s *LanguageServer) completion(document *lspDocument, offset int, _ json.RawMessage) any {
+	items := []map[string]any{}
+	for _, symbol := range document.symbols.Visible(offset) {
+		items = append(items, map[string]any{
+			"label":  symbol.Name,
+			"kind":   lspCompletionKind(symbol.Kind),
+			"detail": fmt.Sprintf("(%s) %s", symbol.Kind, symbol.Detail),
+		})
+	}
+	names := make([]string, 0, len(keywords))
+	for keyword := range keywords {
+		names = append(names, keyword)
+	}
+	sort.Strings(names)
+	for _, keyword := range names {
+		items = append(items, map[string]any{"label": keyword, "kind": lspCompletionKeyword})
+	}
+	return items
+}
+
+func (s *LanguageServer) documentSymbols(uri string) (any, *rpcError) {
+	document, ok := s.documents[uri]
+	if !ok {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("document %q is not open", uri)}
+	}
+	var convert func(symbols []*Symbol) []lspDocumentSymbol
+	convert = func(symbols []*Symbol) []lspDocumentSymbol {
+		converted := []lspDocumentSymbol{}
+		for _, symbol := range symbols {
+			converted = append(converted, lspDocumentSymbol{
+				Name:           symbol.Name,
+				Detail:         symbol.Detail,
+				Kind:           lspSymbolKind(symbol.Kind),
+				Range:          document.lspRange(symbol.Range),
+				SelectionRange: document.lspRange(symbol.Token.Span()),
+				Children:       convert(symbol.Children),
+			})
+		}
+		return converted
+	}
+	return convert(document.symbols.Outline), nil
+}
+
+// lspDocument is an open file together with everything derived from it.
+type lspDocument struct {
+	uri     string
+	text    []byte
+	errors  []error
+	symbols *SymbolTable
+}
+
+func newLspDocument(uri, text string) *lspDocument {
+	source := []byte(text)
+	scanner := NewScanner(source)
+	tokens, scanErrors := scanner.ScanTokens()
+	parser := NewParser(tokens)
+	statements, parseErrors := parser.Parse()
+	return &lspDocument{
+		uri:     uri,
+		text:    source,
+		errors:  checkParsed(statements, scanErrors, parseErrors),
+		symbols: NewSymbolTable(statements),
+	}
+}
+
+// offset converts an LSP position, whose character counts UTF-16 code
+// units, to a byte offset. Positions past the end of a line are clamped.
+func (d *lspDocument) offset(position lspPosition) int {
+	offset := 0
+	for line := 0; line < position.Line; line++ {
+		next := strings.IndexByte(string(d.text[offset:]), '\n')
+		if next < 0 {
+			return len(d.text)
+		}
+		offset += next + 1
+	}
+	for units := 0; units < position.Character && offset < len(d.text) && d.text[offset] != '\n'; {
+		r, size := utf8.DecodeRune(d.text[offset:])
+		units += max(utf16.RuneLen(r), 1)
+		offset += size
+	}
+	return offset
+}
+
+func (d *lspDocument) position(offset int) lspPosition {
+	offset = min(max(offset, 0), len(d.text))
+	var position lspPosition
+	lineStart := 0
+	for n := 0; n < offset; n++ {
+		if d.text[n] == '\n' {
+			position.Line++
+			lineStart = n + 1
+		}
+	}
+	for _, r := range string(d.text[lineStart:offset]) {
+		position.Character += max(utf16.RuneLen(r), 1)
+	}
+	return position
+}
+
+func (d *lspDocument) lspRange(span Span) lspRange {
+	return lspRange{Start: d.position(span.Start.Offset), End: d.position(span.End.Offset)}
+}
+
+// protocol types
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type lspTextDocumentItem struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspPublishDiagnostics struct {
+	URI         string          `json:"uri"`
+	Diagnostics []lspDiagnostic `json:"diagnostics"`
+}
+
+type lspDiagnostic struct {
+	Range              lspRange                `json:"range"`
+	Severity           int                     `json:"severity"`
+	Code               string                  `json:"code"`
+	Source             string                  `json:"source"`
+	Message            string                  `json:"message"`
+	RelatedInformation []lspRelatedInformation `json:"relatedInformation,omitempty"`
+}
+
+type lspRelatedInformation struct {
+	Location lspLocation `json:"location"`
+	Message  string      `json:"message"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string              `json:"name"`
+	Detail         string              `json:"detail"`
+	Kind           int                 `json:"kind"`
+	Range          lspRange            `json:"range"`
+	SelectionRange lspRange            `json:"selectionRange"`
+	Children       []lspDocumentSymbol `json:"children"`
+}
+
+const lspCompletionKeyword = 14
+
+func lspCompletionKind(kind SymbolKind) int {
+	switch kind {
+	case SymbolFunction:
+		return 3
+	case SymbolClass:
+		return 7
+	default:
+		return 6 // variable
+	}
+}
+
+func lspSymbolKind(kind SymbolKind) int {
+	switch kind {
+	case SymbolFunction:
+		return 12
+	case SymbolClass:
+		return 5
+	case SymbolMethod:
+		return 6
+	default:
+		return 13 // variable
+	}
+}
+
+func lspSeverity(severity Severity) int {
+	if severity == SeverityWarning {
+		return 2
+	}
+	return 1 // error
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"math"
+	"math/bits"
+)
+
+// DivisionByZero chooses what dividing a float by zero does, in both
+// backends. An int divided by zero with `~/' or `%' is always an error,
+// there being no int for the result; `/' promotes ints to floats first.
+type DivisionByZero int
+
+const (
+	// DivisionByZeroError stops the program with a RuntimeError. It is the
+	// default.
+	DivisionByZeroError DivisionByZero = iota
+	// DivisionByZeroIEEE follows IEEE 754 and gives Infinity, -Infinity or
+	// NaN.
+	DivisionByZeroIEEE
+)
+
+// ParseDivisionByZero reads the mode names used on the command line,
+// "error" and "ieee".
+func ParseDivisionByZero(name string) (DivisionByZero, bool) {
+	switch name {
+	case "error":
+		return DivisionByZeroError, true
+	case "ieee":
+		return DivisionByZeroIEEE, true
+	}
+	return DivisionByZeroError, false
+}
+
+// binaryOperation applies an arithmetic or comparison operator for the
+// Interpreter and the VM alike. When the operands do not fit the operator
+// it returns the message of the RuntimeError to report at the operator.
+//
+// Two ints give an int, checked for overflow, except that `/' always
+// divides exactly and so gives a float. An int with a float is promoted to
+// a float.
+func binaryOperation(op TokenType, left, right Value, division DivisionByZero) (Value, string) {
+	if op == PLUS {
+		if left.IsString() && right.IsString() {
+			return StringValue(left.AsString() + right.AsString()), ""
+		}
+		if !left.IsNumber() || !right.IsNumber() {
+			return NilValue(), "Operands must be two numbers or two strings."
+		}
+	}
+	if !left.IsNumber() || !right.IsNumber() {
+		return NilValue(), "Operands must be numbers."
+	}
+	if left.IsInt() && right.IsInt() && op != SLASH {
+		return intOperation(op, left.AsInt(), right.AsInt())
+	}
+	return floatOperation(op, left.AsFloat(), right.AsFloat(), division)
+}
+
+// intOperation is binaryOperation on two ints. `~/' and `%' round the
+// quotient down, so a % b takes the sign of b and
+// a == (a ~/ b) * b + a % b. Dividing an int by zero is always an error.
+func intOperation(op TokenType, a, b int64) (Value, string) {
+	switch op {
+	case PLUS:
+		if c := a + b; (c > a) == (b > 0) {
+			return IntValue(c), ""
+		}
+	case MINUS:
+		if c := a - b; (c < a) == (b > 0) {
+			return IntValue(c), ""
+		}
+	case STAR:
+		if hi, lo := bits.Mul64(uint64(abs(a)), uint64(abs(b))); hi == 0 {
+			negative := (a < 0) != (b < 0)
+			if lo <= math.MaxInt64 || (negative && lo == -math.MinInt64) {
+				if negative {
+					return IntValue(-int64(lo)), ""
+				}
+				return IntValue(int64(lo)), ""
+			}
+		}
+	case TILDE_SLASH, PERCENT:
+		if b == 0 {
+			return NilValue(), "Division by zero."
+		}
+		if op == PERCENT {
+			if b == -1 {
+				return IntValue(0), ""
+			}
+			r := a % b
+			if r != 0 && (r < 0) != (b < 0) {
+				r += b
+			}
+			return IntValue(r), ""
+		}
+		if a == math.MinInt64 && b == -1 {
+			break
+		}
+		q := a / b
+		if a%b != 0 && (a < 0) != (b < 0) {
+			q--
+		}
+		return IntValue(q), ""
+	case GREATER:
+		return BoolValue(a > b), ""
+	case GREATER_EQUAL:
+		return BoolValue(a >= b), ""
+	case LESS:
+		return BoolValue(a < b), ""
+	case LESS_EQUAL:
+		return BoolValue(a <= b), ""
+	default:
+		return NilValue(), "Unknown operator."
+	}
+	return NilValue(), "Integer overflow."
+}
+
+// abs is the magnitude of n, which for math.MinInt64 only fits as a uint64.
+func abs(n int64) uint64 {
+	if n < 0 {
+		return -uint64(n)
+	}
+	return uint64(n)
+}
+
+// floatOperation is binaryOperation on two floats, or on an int promoted
+// to one.
+func floatOperation(op TokenType, a, b float64, division DivisionByZero) (Value, string) {
+	switch op {
+	case PLUS:
+		return FloatValue(a + b), ""
+	case MINUS:
+		return FloatValue(a - b), ""
+	case STAR:
+		return FloatValue(a * b), ""
+	case SLASH, TILDE_SLASH, PERCENT:
+		if b == 0 && division == DivisionByZeroError {
+			return NilValue(), "Division by zero."
+		}
+		// Go divides floats by zero the IEEE 754 way.
+		switch op {
+		case TILDE_SLASH:
+			return FloatValue(math.Floor(a / b)), ""
+		case PERCENT:
+			r := math.Mod(a, b)
+			if r != 0 && (r < 0) != (b < 0) {
+				r += b
+			}
+			return FloatValue(r), ""
+		}
+		return FloatValue(a / b), ""
+	case GREATER:
+		return BoolValue(a > b), ""
+	case GREATER_EQUAL:
+		return BoolValue(a >= b), ""
+	case LESS:
+		return BoolValue(a < b), ""
+	case LESS_EQUAL:
+		return BoolValue(a <= b), ""
+	}
+	return NilValue(), "Unknown operator."
+}
+
+// negate applies unary minus, returning an error message as binaryOperation
+// does.
+func negate(operand Value) (Value, string) {
+	switch {
+	case operand.IsInt():
+		if operand.AsInt() == math.MinInt64 {
+			return NilValue(), "Integer overflow."
+		}
+		return IntValue(-operand.AsInt()), ""
+	case operand.IsFloat():
+		return FloatValue(-operand.AsFloat()), ""
+	}
+	return NilValue(), "Operand must be a number."
+}
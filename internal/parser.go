@@ -1,32 +1,75 @@
 package internal
 
-import "fmt"
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
 
 type Parser struct {
 	Tokens  []Token
 	Current int
+	Errors  []error
+
+	functionDepth int
+	// docs are the DOC_COMMENT tokens, which NewParser takes out of Tokens.
+	docs []Token
 }
 
 func NewParser(tokens []Token) Parser {
-	return Parser{
-		Tokens: tokens,
+	p := Parser{Tokens: tokens}
+	for n, token := range tokens {
+		if token.TokenType == DOC_COMMENT {
+			p.Tokens = slices.Clone(tokens[:n])
+			for _, token := range tokens[n:] {
+				if token.TokenType == DOC_COMMENT {
+					p.docs = append(p.docs, token)
+				} else {
+					p.Tokens = append(p.Tokens, token)
+				}
+			}
+			break
+		}
 	}
+	return p
 }
 
-func (p *Parser) Parse() []Stmt {
+// Parse returns the statements of the program together with every
+// ParseError reported while parsing it.
+func (p *Parser) Parse() ([]Stmt, []error) {
 	statements := []Stmt{}
 	for p.Tokens[p.Current].TokenType != EOF {
-		statements = append(statements, p.Declaration())
+		if stmt := p.Declaration(); stmt != nil {
+			statements = append(statements, stmt)
+		}
 	}
-	return statements
+	return statements, p.Errors
 }
 
-func (p *Parser) Declaration() Stmt {
+// Declaration parses a single declaration or statement. When it fails, the
+// error is already recorded, the parser skips to the start of the next
+// statement and nil is returned so parsing can carry on.
+func (p *Parser) Declaration() (stmt Stmt) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(*ParseError); !ok {
+				panic(r)
+			}
+			p.Synchronize()
+			stmt = nil
+		}
+	}()
+
 	if p.Match(VAR) {
 		p.Current++
 		return p.VarDeclaration()
 	}
 
+	if p.Match(CLASS) {
+		p.Current++
+		return p.ClassDeclaration()
+	}
+
 	if p.Match(FUN) {
 		p.Current++
 		return p.Function("function")
@@ -34,7 +77,31 @@ func (p *Parser) Declaration() Stmt {
 	return p.Statement()
 }
 
+func (p *Parser) ClassDeclaration() Stmt {
+	keyword := p.Tokens[p.Current-1]
+	p.Consume(IDENTIFIER, "Expected class name.")
+	name := p.Tokens[p.Current-1]
+
+	var superclass *Variable
+	if p.Match(LESS) {
+		p.Current++
+		p.Consume(IDENTIFIER, "Expected superclass name.")
+		superclass = &Variable{p.Tokens[p.Current-1]}
+	}
+
+	p.Consume(LEFT_BRACE, "Expected opening brace `{' before class body.")
+
+	methods := []*FunctionStmt{}
+	for !p.Match(RIGHT_BRACE) && !p.Match(EOF) {
+		methods = append(methods, p.Function("method").(*FunctionStmt))
+	}
+	p.Consume(RIGHT_BRACE, "Expected closing brace `}' after class body.")
+	return ClassStmt{name, superclass, methods, p.spanFrom(keyword)}
+}
+
 func (p *Parser) VarDeclaration() Stmt {
+	at := p.Current - 1
+	keyword := p.Tokens[at]
 	p.Consume(IDENTIFIER, "Expected variable name.")
 	name := p.Tokens[p.Current-1]
 	var val Expr
@@ -43,28 +110,65 @@ func (p *Parser) VarDeclaration() Stmt {
 		val = p.Expression()
 	}
 	p.Consume(SEMICOLON, "Expected semicolon `;' after variable declaration.")
-	return VarDeclare{name.Lexeme, val}
+	return VarDeclare{name, val, p.spanFrom(keyword), p.doc(at)}
 }
 
 func (p *Parser) Function(kind string) Stmt {
-	p.Consume(IDENTIFIER, "Expected "+kind+" name")
+	at := p.Current
+	if p.Current > 0 && p.Tokens[p.Current-1].TokenType == FUN {
+		at--
+	}
+	start := p.Tokens[at]
+	p.Consume(IDENTIFIER, "Expected "+kind+" name.")
 	name := p.Tokens[p.Current-1]
-	p.Consume(LEFT_PAREN, "Expected left_paren name")
+	p.Consume(LEFT_PAREN, "Expected opening parenthesis '(' after "+kind+" name.")
 	params := []Token{}
 	if p.Tokens[p.Current].TokenType != RIGHT_PAREN {
 		for {
-			p.Consume(IDENTIFIER, "Expected parameter name")
+			p.Consume(IDENTIFIER, "Expected parameter name.")
 			params = append(params, p.Tokens[p.Current-1])
-			if p.Tokens[p.Current].TokenType != COMMA {
+			if !p.Match(COMMA) {
 				break
 			}
+			p.Current++
 		}
 	}
-	p.Consume(RIGHT_PAREN, "Expected right_paren name")
+	p.Consume(RIGHT_PAREN, "Expected closing parenthesis ')' after parameters.")
+
+	p.Consume(LEFT_BRACE, "Expected opening brace `{' before "+kind+" body.")
+	p.functionDepth++
+	defer func() { p.functionDepth-- }()
+	body := p.BlockStatements()
+	return &FunctionStmt{name, params, body, p.spanFrom(start), p.doc(at)}
+}
 
-  p.Consume(LEFT_BRACE, "Expected left_brace ")
-	body := p.BlockStmt()
-	return &FunctionStmt{name, params, body}
+// doc returns the text of the doc comments on the lines right above
+// Tokens[at], the first token of a declaration, without their `///' and one
+// space after it. Comments separated from the declaration by a blank line,
+// or sharing a line with the code before, document nothing.
+func (p *Parser) doc(at int) string {
+	start := p.Tokens[at]
+	end, _ := slices.BinarySearchFunc(p.docs, start.Offset, func(doc Token, offset int) int {
+		return cmp.Compare(doc.Offset, offset)
+	})
+	first, line := end, start.Line
+	for first > 0 && p.docs[first-1].Line == line-1 {
+		first--
+		line--
+	}
+	if first == end {
+		return ""
+	}
+	if at > 0 && p.Tokens[at-1].Line >= p.docs[first].Line {
+		return ""
+	}
+
+	lines := make([]string, 0, end-first)
+	for _, doc := range p.docs[first:end] {
+		text := strings.TrimPrefix(doc.Lexeme, "///")
+		lines = append(lines, strings.TrimPrefix(text, " "))
+	}
+	return strings.Join(lines, "\n")
 }
 
 func (p *Parser) Statement() Stmt {
@@ -97,31 +201,61 @@ func (p *Parser) Statement() Stmt {
 		p.Current++
 		return p.ForStmt()
 	}
+
+	if p.Match(RETURN) {
+		p.Current++
+		return p.ReturnStmt()
+	}
 	return p.ExpressionStmt()
 }
 
 func (p *Parser) PrintStmt() Stmt {
+	keyword := p.Tokens[p.Current-1]
 	expr := p.Expression()
 	p.Consume(SEMICOLON, "Expected semicolon `;' after expression.")
-	return Print{expr}
+	return Print{expr, p.spanFrom(keyword)}
+}
+
+func (p *Parser) ReturnStmt() Stmt {
+	keyword := p.Tokens[p.Current-1]
+	if p.functionDepth == 0 {
+		p.error(keyword, "Can't return from top-level code.")
+	}
+
+	var value Expr
+	if !p.Match(SEMICOLON) {
+		value = p.Expression()
+	}
+	p.Consume(SEMICOLON, "Expected semicolon `;' after return value.")
+	return ReturnStmt{keyword, value, p.spanFrom(keyword)}
 }
 
 func (p *Parser) ExpressionStmt() Stmt {
 	expr := p.Expression()
 	p.Consume(SEMICOLON, "Expected semicolon `;' after expression.")
-	return Expression{expr}
+	return Expression{expr, SpanBetween(expr.Span(), p.Tokens[p.Current-1].Span())}
 }
 
 func (p *Parser) BlockStmt() Stmt {
+	brace := p.Tokens[p.Current-1]
+	return Block{p.BlockStatements(), p.spanFrom(brace)}
+}
+
+// BlockStatements parses the statements of a block whose `{' has already
+// been consumed, up to and including the closing `}'.
+func (p *Parser) BlockStatements() []Stmt {
 	statements := []Stmt{}
 	for !p.Match(RIGHT_BRACE) && p.Tokens[p.Current].TokenType != EOF {
-		statements = append(statements, p.Declaration())
+		if stmt := p.Declaration(); stmt != nil {
+			statements = append(statements, stmt)
+		}
 	}
 	p.Consume(RIGHT_BRACE, "Expected closing brace `}' after block.")
-	return Block{statements}
+	return statements
 }
 
 func (p *Parser) IfStmt() Stmt {
+	keyword := p.Tokens[p.Current-1]
 	p.Consume(LEFT_PAREN, "Expected opening parenthesis '(' after if.")
 	condition := p.Expression()
 	p.Consume(RIGHT_PAREN, "Expected closing parenthesis ')' after expression.")
@@ -132,7 +266,7 @@ func (p *Parser) IfStmt() Stmt {
 		p.Current++
 		elseBranch = p.Statement()
 	}
-	return IfStmt{condition, thenBranch, elseBranch}
+	return IfStmt{condition, thenBranch, elseBranch, p.spanFrom(keyword)}
 }
 
 func (p *Parser) Expression() Expr {
@@ -140,15 +274,17 @@ func (p *Parser) Expression() Expr {
 }
 
 func (p *Parser) WhileStmt() Stmt {
-	p.Consume(LEFT_PAREN, "Expected opening parenthesis '(' after while statement")
+	keyword := p.Tokens[p.Current-1]
+	p.Consume(LEFT_PAREN, "Expected opening parenthesis '(' after while.")
 	cond := p.Expression()
-	p.Consume(RIGHT_PAREN, "Expected closing parenthesis ')' after expression")
+	p.Consume(RIGHT_PAREN, "Expected closing parenthesis ')' after condition.")
 	body := p.Statement()
-	return &WhileStmt{cond, body}
+	return &WhileStmt{cond, body, p.spanFrom(keyword)}
 }
 
 func (p *Parser) ForStmt() Stmt {
-	p.Consume(LEFT_PAREN, "Expected opening parenthesis '(' after for statement")
+	keyword := p.Tokens[p.Current-1]
+	p.Consume(LEFT_PAREN, "Expected opening parenthesis '(' after for.")
 	var Initializer Stmt
 	if p.Match(SEMICOLON) {
 		p.Current++
@@ -170,27 +306,28 @@ func (p *Parser) ForStmt() Stmt {
 	if !p.Match(RIGHT_PAREN) {
 		Increment = p.Expression()
 	}
-	p.Consume(RIGHT_PAREN, "Expected opening parenthesis ')' after clauses")
+	p.Consume(RIGHT_PAREN, "Expected closing parenthesis ')' after for clauses.")
 
 	Body := p.Statement()
+	span := p.spanFrom(keyword)
 
 	if Increment != nil {
 		Body = Block{[]Stmt{
 			Body,
-			Expression{Increment},
-		}}
+			Expression{Increment, Increment.Span()},
+		}, span}
 	}
 
 	if Cond == nil {
-		Cond = &Literal{true}
+		Cond = &Literal{true, keyword}
 	}
-	Body = &WhileStmt{Cond, Body}
+	Body = &WhileStmt{Cond, Body, span}
 
 	if Initializer != nil {
 		Body = Block{[]Stmt{
 			Initializer,
 			Body,
-		}}
+		}, span}
 	}
 	return Body
 }
@@ -198,14 +335,17 @@ func (p *Parser) ForStmt() Stmt {
 func (p *Parser) Assignment() Expr {
 	expr := p.Or()
 	if p.Match(EQUAL) {
+		equals := p.Tokens[p.Current]
 		p.Current++
 		value := p.Assignment()
-		expr, ok := expr.(*Variable)
-		if ok {
-			return &Assignment{expr.Name, value}
+		switch target := expr.(type) {
+		case *Variable:
+			return &Assignment{target.Name, value}
+		case *Get:
+			return &Set{target.Object, target.Name, value}
 		}
 
-		panic("Invalid assignment target")
+		p.error(equals, "Invalid assignment target.")
 	}
 	return expr
 }
@@ -267,7 +407,7 @@ func (p *Parser) Term() Expr {
 
 func (p *Parser) Factor() Expr {
 	expr := p.Unary()
-	for p.Match(SLASH, STAR) {
+	for p.Match(SLASH, STAR, PERCENT, TILDE_SLASH) {
 		op := p.Tokens[p.Current]
 		p.Current++
 		right := p.Unary()
@@ -292,6 +432,10 @@ func (p *Parser) Call() Expr {
 		if p.Match(LEFT_PAREN) {
 			p.Current++
 			expr = p.FinishCall(expr)
+		} else if p.Match(DOT) {
+			p.Current++
+			p.Consume(IDENTIFIER, "Expected property name after '.'.")
+			expr = &Get{expr, p.Tokens[p.Current-1]}
 		} else {
 			break
 		}
@@ -319,32 +463,83 @@ func (p *Parser) Primary() Expr {
 	switch p.Tokens[p.Current].TokenType {
 	case FALSE:
 		p.Current++
-		return &Literal{false}
+		return &Literal{false, p.Tokens[p.Current-1]}
 	case TRUE:
 		p.Current++
-		return &Literal{true}
+		return &Literal{true, p.Tokens[p.Current-1]}
 	case NIL:
 		p.Current++
-		return &Literal{nil}
+		return &Literal{nil, p.Tokens[p.Current-1]}
 	case NUMBER, STRING:
-		literal := p.Tokens[p.Current].Literal
+		token := p.Tokens[p.Current]
 		p.Current++
-		return &Literal{literal}
+		return &Literal{token.Literal, token}
+	case INTERPOLATION:
+		return p.interpolation()
 	case IDENTIFIER:
 		token := p.Tokens[p.Current]
 		p.Current++
 		return &Variable{token}
+	case THIS:
+		token := p.Tokens[p.Current]
+		p.Current++
+		return &This{token}
+	case SUPER:
+		keyword := p.Tokens[p.Current]
+		p.Current++
+		p.Consume(DOT, "Expected '.' after 'super'.")
+		p.Consume(IDENTIFIER, "Expected superclass method name.")
+		return &Super{keyword, p.Tokens[p.Current-1]}
 	case LEFT_PAREN:
+		leftParen := p.Tokens[p.Current]
 		p.Current++
 		expr := p.Expression()
 		p.Consume(RIGHT_PAREN, "Expected closing parenthesis ')' after expression.")
-		return &Grouping{expr}
+		return &Grouping{expr, leftParen, p.Tokens[p.Current-1]}
 	default:
-		return &Literal{"Token not supported"}
+		panic(p.error(p.Tokens[p.Current], "Expected expression."))
 	}
 }
 
+// interpolation parses the segments and expressions the scanner splits
+// "a ${b} c" into, see Scanner.ProcessString.
+//
+// When no string segment is left to close it, the `${' was never closed.
+// The Scanner has reported that already and the rest of the file was read as
+// its expression, so the errors found in there are dropped.
+func (p *Parser) interpolation() Expr {
+	errors := len(p.Errors)
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(*ParseError); ok && !p.segmentAhead() {
+				p.Errors = p.Errors[:errors]
+			}
+			panic(r)
+		}
+	}()
+
+	expr := &Interpolation{}
+	for p.Match(INTERPOLATION) {
+		expr.Segments = append(expr.Segments, p.Tokens[p.Current])
+		p.Current++
+		expr.Expressions = append(expr.Expressions, p.Expression())
+	}
+	p.Consume(STRING, "Expected '}' after interpolated expression.")
+	expr.Segments = append(expr.Segments, p.Tokens[p.Current-1])
+	return expr
+}
+
 // helper
+// segmentAhead reports whether a STRING or INTERPOLATION token is left.
+func (p *Parser) segmentAhead() bool {
+	for _, token := range p.Tokens[p.Current:] {
+		if token.TokenType == STRING || token.TokenType == INTERPOLATION {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Parser) Match(tokenTypes ...TokenType) bool {
 	for _, tokenType := range tokenTypes {
 		if p.Tokens[p.Current].TokenType == tokenType {
@@ -359,26 +554,38 @@ func (p *Parser) Consume(expected TokenType, message string) {
 		p.Current++
 		return
 	}
-	panic(fmt.Sprintf("%s.. Got %s ", message, p.Tokens[p.Current]))
+	panic(p.error(p.Tokens[p.Current], message))
+}
+
+// spanFrom covers the source from start up to the last consumed token.
+func (p *Parser) spanFrom(start Token) Span {
+	return SpanBetween(start.Span(), p.Tokens[p.Current-1].Span())
+}
+
+// error records a ParseError. Callers that cannot continue panic with the
+// returned error, which Parse recovers from.
+func (p *Parser) error(token Token, message string) *ParseError {
+	err := &ParseError{Token: token, Message: message}
+	p.Errors = append(p.Errors, err)
+	return err
 }
 
+// Synchronize discards tokens until it has probably reached the start of the
+// next statement: just after a `;' or at a keyword that begins a statement.
 func (p *Parser) Synchronize() {
-	for p.Current < len(p.Tokens) {
+	if p.Match(EOF) {
+		return
+	}
+	p.Current++
+	for !p.Match(EOF) {
 		if p.Tokens[p.Current-1].TokenType == SEMICOLON {
 			return
 		}
 
 		switch p.Tokens[p.Current].TokenType {
-		case CLASS:
-		case FUN:
-		case VAR:
-		case FOR:
-		case IF:
-		case WHILE:
-		case PRINT:
-		case RETURN:
+		case CLASS, FUN, VAR, FOR, IF, WHILE, PRINT, RETURN:
 			return
 		}
+		p.Current++
 	}
-	p.Current++
 }
@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Repl reads declarations and statements line by line and runs them against
+// one long-lived Interpreter, so globals survive from one input to the next.
+// Every input is kept in History: `:history' lists it and `:redo' runs the
+// last input again, `:redo n' the nth. Lines are read as they come, so there
+// is no arrow-key editing.
+type Repl struct {
+	Interpreter *Interpreter
+	History     []string
+	HistoryFile string
+	// Color paints error diagnostics with ANSI colors.
+	Color bool
+
+	out    io.Writer
+	errOut io.Writer
+}
+
+func NewRepl(out, errOut io.Writer) *Repl {
+	interpreter := NewInterpreter()
+	interpreter.SetOutput(out)
+	return &Repl{
+		Interpreter: interpreter,
+		out:         out,
+		errOut:      errOut,
+	}
+}
+
+// LoadHistory reads previous inputs from HistoryFile. A missing file is not
+// an error.
+func (r *Repl) LoadHistory() error {
+	if r.HistoryFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(r.HistoryFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range strings.Split(string(data), "\x00") {
+		if entry != "" {
+			r.History = append(r.History, entry)
+		}
+	}
+	return nil
+}
+
+// Run reads from in until it is exhausted. Input is buffered across lines
+// while it is incomplete: braces or parentheses are still open, or a string
+// or block comment has not been closed.
+func (r *Repl) Run(in io.Reader) {
+	lines := bufio.NewScanner(in)
+	var pending strings.Builder
+
+	fmt.Fprint(r.out, "> ")
+	for lines.Scan() {
+		line := lines.Text()
+		if pending.Len() == 0 && r.command(strings.TrimSpace(line)) {
+			fmt.Fprint(r.out, "> ")
+			continue
+		}
+
+		pending.WriteString(line)
+		pending.WriteString("\n")
+		if incomplete(pending.String()) {
+			fmt.Fprint(r.out, "... ")
+			continue
+		}
+
+		source := pending.String()
+		pending.Reset()
+		if strings.TrimSpace(source) != "" {
+			r.addHistory(strings.TrimSuffix(source, "\n"))
+			r.Eval(source)
+		}
+		fmt.Fprint(r.out, "> ")
+	}
+	fmt.Fprintln(r.out)
+}
+
+// Eval runs one complete input. The value of every bare expression statement
+// is printed; errors are reported and leave the session usable.
+func (r *Repl) Eval(source string) {
+	statements, scanErrors, parseErrors := parseSource(source)
+	if len(parseErrors) > 0 && len(scanErrors) == 0 {
+		// Let a lone expression be typed without its trailing `;'.
+		if retried, _, retryErrors := parseSource(source + ";"); len(retryErrors) == 0 {
+			statements, parseErrors = retried, nil
+		}
+	}
+	if staticErrors := append(scanErrors, parseErrors...); len(staticErrors) > 0 {
+		r.report(source, staticErrors)
+		return
+	}
+
+	resolver := NewResolver(r.Interpreter)
+	resolveErrors := resolver.Resolve(statements)
+	if diagnostics := append(resolveErrors, resolver.Warnings...); len(diagnostics) > 0 {
+		r.report(source, diagnostics)
+	}
+	if len(resolveErrors) > 0 {
+		return
+	}
+
+	for _, stmt := range statements {
+		if expression, ok := stmt.(Expression); ok {
+			value, err := r.Interpreter.Evaluate(expression.Expr)
+			if err != nil {
+				r.report(source, []error{err})
+				return
+			}
+			fmt.Fprintln(r.out, value)
+			continue
+		}
+		if err := r.Interpreter.Interpret([]Stmt{stmt}); err != nil {
+			r.report(source, []error{err})
+			return
+		}
+	}
+}
+
+func parseSource(source string) ([]Stmt, []error, []error) {
+	scanner := NewScanner([]byte(source))
+	tokens, scanErrors := scanner.ScanTokens()
+	parser := NewParser(tokens)
+	statements, parseErrors := parser.Parse()
+	return statements, scanErrors, parseErrors
+}
+
+// command handles the REPL's own `:' commands and reports whether line was
+// one of them.
+func (r *Repl) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	switch name {
+	case ":history":
+		for n, entry := range r.History {
+			fmt.Fprintf(r.out, "%4d  %s\n", n+1, entry)
+		}
+		return true
+	case ":redo":
+		n := len(r.History)
+		if arg = strings.TrimSpace(arg); arg != "" {
+			var err error
+			if n, err = strconv.Atoi(arg); err != nil {
+				n = 0
+			}
+		}
+		if n < 1 || n > len(r.History) {
+			fmt.Fprintln(r.errOut, "No such history entry.")
+			return true
+		}
+		entry := r.History[n-1]
+		fmt.Fprintln(r.out, entry)
+		r.addHistory(entry)
+		r.Eval(entry + "\n")
+		return true
+	}
+	return false
+}
+
+func (r *Repl) addHistory(entry string) {
+	r.History = append(r.History, entry)
+	if r.HistoryFile == "" {
+		return
+	}
+	file, err := os.OpenFile(r.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprint(file, entry+"\x00")
+}
+
+// report renders errs against the input they were found in, which the
+// diagnostics call <repl>.
+func (r *Repl) report(source string, errs []error) {
+	printer := DiagnosticPrinter{File: "<repl>", Source: []byte(source), Color: r.Color}
+	fmt.Fprint(r.errOut, printer.RenderErrors(errs))
+}
+
+// incomplete reports whether source stops in the middle of something: with
+// braces or parentheses still open, or inside a string, a `${' expression or
+// a block comment.
+func incomplete(source string) bool {
+	scanner := NewScanner([]byte(source))
+	tokens, _ := scanner.ScanTokens()
+	if scanner.unterminated {
+		return true
+	}
+	depth := 0
+	for _, token := range tokens {
+		switch token.TokenType {
+		case LEFT_BRACE, LEFT_PAREN:
+			depth++
+		case RIGHT_BRACE, RIGHT_PAREN:
+			depth--
+		}
+	}
+	return depth > 0
+}
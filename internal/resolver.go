@@ -0,0 +1,329 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+)
+
+type functionType int
+
+const (
+	noFunction functionType = iota
+	inFunction
+	inMethod
+	inInitializer
+)
+
+type classType int
+
+const (
+	noClass classType = iota
+	inClass
+	inSubclass
+)
+
+// scopeVariable is what the Resolver knows about a local while its scope is
+// open. Defined stays false until the initializer has been resolved.
+type scopeVariable struct {
+	Name    Token
+	Defined bool
+	Used    bool
+	IsVar   bool
+}
+
+// Resolver walks the program once before it runs and tells the Interpreter
+// how many scopes lie between each local variable use and its declaration.
+type Resolver struct {
+	interpreter     *Interpreter
+	scopes          []map[string]*scopeVariable
+	currentFunction functionType
+	currentClass    classType
+	Errors          []error
+	// Warnings holds ResolveWarnings, which do not stop the program from
+	// running.
+	Warnings []error
+}
+
+func NewResolver(interpreter *Interpreter) Resolver {
+	return Resolver{
+		interpreter: interpreter,
+	}
+}
+
+// Resolve annotates the interpreter with scope depths for statements and
+// returns every ResolveError found. Warnings are left in Warnings.
+func (r *Resolver) Resolve(statements []Stmt) []error {
+	r.resolveStatements(statements)
+	return r.Errors
+}
+
+func (r *Resolver) resolveStatements(statements []Stmt) {
+	for _, stmt := range statements {
+		stmt.Apply(r)
+	}
+}
+
+func (r *Resolver) resolveFunction(function FunctionStmt, kind functionType) {
+	enclosingFunction := r.currentFunction
+	r.currentFunction = kind
+	defer func() { r.currentFunction = enclosingFunction }()
+
+	r.beginScope()
+	for _, param := range function.Params {
+		r.declare(param, false)
+		r.define(param)
+	}
+	r.resolveStatements(function.Body)
+	r.endScope()
+}
+
+func (r *Resolver) resolveLocal(expr Expr, name Token, isRead bool) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if variable, ok := r.scopes[i][name.Lexeme]; ok {
+			if isRead {
+				variable.Used = true
+			}
+			r.interpreter.Resolve(expr, len(r.scopes)-1-i)
+			return
+		}
+	}
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, make(map[string]*scopeVariable))
+}
+
+func (r *Resolver) endScope() {
+	unused := []Token{}
+	for _, variable := range r.scopes[len(r.scopes)-1] {
+		if variable.IsVar && !variable.Used {
+			unused = append(unused, variable.Name)
+		}
+	}
+	sort.Slice(unused, func(a, b int) bool {
+		if unused[a].Line != unused[b].Line {
+			return unused[a].Line < unused[b].Line
+		}
+		return unused[a].Column < unused[b].Column
+	})
+	for _, name := range unused {
+		r.Warnings = append(r.Warnings, &ResolveWarning{Token: name, Message: "Local variable is never used."})
+	}
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// declare adds name to the innermost scope. Only `var' declarations are
+// reported when they are never read; parameters and functions are not.
+func (r *Resolver) declare(name Token, isVar bool) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	scope := r.scopes[len(r.scopes)-1]
+	if previous, exists := scope[name.Lexeme]; exists {
+		r.Errors = append(r.Errors, &ResolveError{
+			Token:   name,
+			Message: "Already a variable with this name in this scope.",
+			Notes: []Note{{
+				Message: fmt.Sprintf("'%s' was first declared at %d:%d.", name.Lexeme, previous.Name.Line, previous.Name.Column),
+				Span:    previous.Name.Span(),
+			}},
+		})
+	}
+	scope[name.Lexeme] = &scopeVariable{Name: name, IsVar: isVar}
+}
+
+func (r *Resolver) define(name Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.scopes[len(r.scopes)-1][name.Lexeme].Defined = true
+}
+
+func (r *Resolver) error(token Token, message string) {
+	r.Errors = append(r.Errors, &ResolveError{Token: token, Message: message})
+}
+
+// statements
+
+func (r *Resolver) VisitBlock(stmt Block) any {
+	r.beginScope()
+	r.resolveStatements(stmt.Statements)
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) VisitVarDeclare(stmt VarDeclare) any {
+	r.declare(stmt.Name, true)
+	if stmt.InitialExpr != nil {
+		stmt.InitialExpr.Apply(r)
+	}
+	r.define(stmt.Name)
+	return nil
+}
+
+func (r *Resolver) VisitFunctionStmt(stmt FunctionStmt) any {
+	r.declare(stmt.Name, false)
+	r.define(stmt.Name)
+	r.resolveFunction(stmt, inFunction)
+	return nil
+}
+
+func (r *Resolver) VisitClassStmt(stmt ClassStmt) any {
+	enclosingClass := r.currentClass
+	r.currentClass = inClass
+	defer func() { r.currentClass = enclosingClass }()
+
+	r.declare(stmt.Name, false)
+	r.define(stmt.Name)
+
+	if stmt.Superclass != nil {
+		if stmt.Superclass.Name.Lexeme == stmt.Name.Lexeme {
+			r.error(stmt.Superclass.Name, "A class can't inherit from itself.")
+		}
+		r.currentClass = inSubclass
+		stmt.Superclass.Apply(r)
+
+		r.beginScope()
+		r.scopes[len(r.scopes)-1]["super"] = &scopeVariable{Name: stmt.Superclass.Name, Defined: true}
+		defer r.endScope()
+	}
+
+	r.beginScope()
+	r.scopes[len(r.scopes)-1]["this"] = &scopeVariable{Name: stmt.Name, Defined: true}
+	for _, method := range stmt.Methods {
+		kind := inMethod
+		if method.Name.Lexeme == "init" {
+			kind = inInitializer
+		}
+		r.resolveFunction(*method, kind)
+	}
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) VisitExpression(stmt Expression) any {
+	stmt.Expr.Apply(r)
+	return nil
+}
+
+func (r *Resolver) VisitIfStmt(stmt IfStmt) any {
+	stmt.Condition.Apply(r)
+	stmt.ThenBranch.Apply(r)
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch.Apply(r)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitPrint(stmt Print) any {
+	stmt.Expr.Apply(r)
+	return nil
+}
+
+func (r *Resolver) VisitReturnStmt(stmt ReturnStmt) any {
+	if stmt.Value != nil {
+		if r.currentFunction == inInitializer {
+			r.error(stmt.Keyword, "Can't return a value from an initializer.")
+		}
+		stmt.Value.Apply(r)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitWhileStmt(stmt WhileStmt) any {
+	stmt.Condition.Apply(r)
+	stmt.Body.Apply(r)
+	return nil
+}
+
+// expressions
+
+func (r *Resolver) VisitVariableExpr(expr *Variable) any {
+	if len(r.scopes) > 0 {
+		if variable, ok := r.scopes[len(r.scopes)-1][expr.Name.Lexeme]; ok && !variable.Defined {
+			r.error(expr.Name, "Can't read local variable in its own initializer.")
+		}
+	}
+	r.resolveLocal(expr, expr.Name, true)
+	return nil
+}
+
+func (r *Resolver) VisitAssignmentExpr(expr *Assignment) any {
+	expr.Value.Apply(r)
+	r.resolveLocal(expr, expr.Name, false)
+	return nil
+}
+
+func (r *Resolver) VisitBinaryExpr(expr *Binary) any {
+	expr.Left.Apply(r)
+	expr.Right.Apply(r)
+	return nil
+}
+
+func (r *Resolver) VisitCallExpr(expr *Call) any {
+	expr.Callee.Apply(r)
+	for _, arg := range expr.Arguments {
+		arg.Apply(r)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitGroupingExpr(expr *Grouping) any {
+	expr.Inside.Apply(r)
+	return nil
+}
+
+func (r *Resolver) VisitLiteralExpr(expr *Literal) any {
+	return nil
+}
+
+func (r *Resolver) VisitInterpolationExpr(expr *Interpolation) any {
+	for _, part := range expr.Expressions {
+		part.Apply(r)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitLogicalExpr(expr *Logic) any {
+	expr.Left.Apply(r)
+	expr.Right.Apply(r)
+	return nil
+}
+
+func (r *Resolver) VisitUnaryExpr(expr *Unary) any {
+	expr.Right.Apply(r)
+	return nil
+}
+
+func (r *Resolver) VisitGetExpr(expr *Get) any {
+	expr.Object.Apply(r)
+	return nil
+}
+
+func (r *Resolver) VisitSetExpr(expr *Set) any {
+	expr.Value.Apply(r)
+	expr.Object.Apply(r)
+	return nil
+}
+
+func (r *Resolver) VisitThisExpr(expr *This) any {
+	if r.currentClass == noClass {
+		r.error(expr.Keyword, "Can't use 'this' outside of a class.")
+		return nil
+	}
+	r.resolveLocal(expr, expr.Keyword, true)
+	return nil
+}
+
+func (r *Resolver) VisitSuperExpr(expr *Super) any {
+	switch r.currentClass {
+	case noClass:
+		r.error(expr.Keyword, "Can't use 'super' outside of a class.")
+		return nil
+	case inClass:
+		r.error(expr.Keyword, "Can't use 'super' in a class with no superclass.")
+		return nil
+	}
+	r.resolveLocal(expr, expr.Keyword, true)
+	return nil
+}
@@ -1,16 +1,48 @@
 package internal
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Scanner turns source text into Tokens. Comments are not tokens the Parser
+// sees; they are kept aside in Comments, in source order, for tools such as
+// the formatter that must reproduce them. The exception are `///' doc
+// comments, which are also DOC_COMMENT tokens so the Parser can attach them
+// to the declaration they document.
 type Scanner struct {
-	Source  []byte
-	Tokens  []Token
-	Start   int
-	Current int
-	Line    uint16
+	Source    []byte
+	Tokens    []Token
+	Comments  []Token
+	Errors    []error
+	Start     int
+	Current   int
+	Line      int
+	LineStart int
+
+	// startLine and startLineStart are Line and LineStart as they were at
+	// Start, which differ from the current ones inside multi-line strings.
+	startLine      int
+	startLineStart int
+
+	// interpolations holds the strings whose `${' expressions are being
+	// scanned, innermost last.
+	interpolations []interpolation
+	// unterminated is set when the source ends inside a string, a `${'
+	// expression or a block comment, which the REPL takes as a sign that
+	// more input is coming.
+	unterminated bool
+}
+
+// interpolation is a `${' expression inside a string. The `}' that closes
+// it is the first one not matching a `{' opened since.
+type interpolation struct {
+	segment Token
+	braces  int
 }
 
 func NewScanner(source_code []byte) Scanner {
@@ -20,13 +52,31 @@ func NewScanner(source_code []byte) Scanner {
 	}
 }
 
-func (s *Scanner) ScanTokens() []Token {
+// ScanTokens scans the whole source. Characters that cannot be scanned are
+// reported as ScanErrors and skipped, so every problem in the file is returned.
+//
+// A `#!' line at the very start is taken for a comment, so scripts can be
+// run directly on systems that honour it.
+func (s *Scanner) ScanTokens() ([]Token, []error) {
+	if bytes.HasPrefix(s.Source, []byte("#!")) {
+		s.startLine, s.startLineStart = s.Line, s.LineStart
+		s.lineComment()
+		s.Start = s.Current
+	}
 	for s.Current < len(s.Source) {
+		s.startLine, s.startLineStart = s.Line, s.LineStart
 		s.scanToken()
 		s.Start = s.Current
 	}
-	s.Tokens = append(s.Tokens, NewToken(EOF, "EOF", nil, s.Line))
-	return s.Tokens
+	// Everything after the outermost open `${' has been read as part of its
+	// expression, so that is the one error to report.
+	if len(s.interpolations) > 0 {
+		s.unterminated = true
+		s.interpolationError(s.interpolations[0].segment, "Unterminated string interpolation.")
+	}
+	s.startLine, s.startLineStart = s.Line, s.LineStart
+	s.Tokens = append(s.Tokens, s.token(EOF, "EOF", nil))
+	return s.Tokens, s.Errors
 }
 
 func (s *Scanner) scanToken() {
@@ -38,11 +88,36 @@ func (s *Scanner) scanToken() {
 	case ')':
 		s.AddToken(RIGHT_PAREN, nil)
 	case '{':
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1].braces++
+		}
 		s.AddToken(LEFT_BRACE, nil)
 	case '}':
+		if n := len(s.interpolations); n > 0 {
+			if open := s.interpolations[n-1]; open.braces == 0 {
+				s.interpolations = s.interpolations[:n-1]
+				if last := s.Tokens[len(s.Tokens)-1]; last.TokenType == INTERPOLATION && last.Offset == open.segment.Offset {
+					s.emptyInterpolation(open.segment)
+					return
+				}
+				s.ProcessString()
+				return
+			}
+			s.interpolations[n-1].braces--
+		}
 		s.AddToken(RIGHT_BRACE, nil)
+	case ',':
+		s.AddToken(COMMA, nil)
 	case '*':
 		s.AddToken(STAR, nil)
+	case '%':
+		s.AddToken(PERCENT, nil)
+	case '~':
+		if s.match('/') {
+			s.AddToken(TILDE_SLASH, nil)
+		} else {
+			s.error("Unexpected character '~', integer division is written '~/'.")
+		}
 	case '+':
 		s.AddToken(PLUS, nil)
 	case '-':
@@ -50,118 +125,391 @@ func (s *Scanner) scanToken() {
 	case ';':
 		s.AddToken(SEMICOLON, nil)
 	case '.':
-		s.AddToken(DOT, nil)
-	case ' ':
-		{
+		if isDigit(s.peek()) {
+			s.skip(isDigitOrSeparator)
+			s.numberError(fmt.Sprintf("A number can't start with '.', write '0%s' instead.", s.Source[s.Start:s.Current]))
+			return
 		}
+		s.AddToken(DOT, nil)
+	case ' ', '\t', '\r':
 	case '\n':
-		{
-		}
+		s.newline()
 	case '<':
-		if s.Source[s.Current] == '=' {
-			s.Current++
+		if s.match('=') {
 			s.AddToken(LESS_EQUAL, nil)
 		} else {
 			s.AddToken(LESS, nil)
 		}
 	case '>':
-		if s.Source[s.Current] == '=' {
-			s.Current++
+		if s.match('=') {
 			s.AddToken(GREATER_EQUAL, nil)
 		} else {
 			s.AddToken(GREATER, nil)
 		}
 	case '=':
-		if s.Source[s.Current] == '=' {
-			s.Current++
+		if s.match('=') {
 			s.AddToken(EQUAL_EQUAL, nil)
 		} else {
 			s.AddToken(EQUAL, nil)
 		}
 	case '!':
-		if s.Source[s.Current] == '=' {
-			s.Current++
+		if s.match('=') {
 			s.AddToken(BANG_EQUAL, nil)
 		} else {
 			s.AddToken(BANG, nil)
 		}
 	case '/':
-		if s.Source[s.Current] == '/' {
-			for s.Current < len(s.Source) && s.Source[s.Current] != '\n' {
-				s.Current++
-			}
+		if s.match('/') {
+			s.lineComment()
+		} else if s.match('*') {
+			s.blockComment()
 		} else {
 			s.AddToken(SLASH, nil)
 		}
 	case '"':
 		s.ProcessString()
 	default:
-		if isAlpha(c) {
-			s.ProcessIdentifier()
-		} else if isDigit(c) {
+		if isDigit(c) {
 			s.ProcessNumber()
+			return
+		}
+		r, size := utf8.DecodeRune(s.Source[s.Start:])
+		s.Current = s.Start + size
+		if r == utf8.RuneError && size == 1 {
+			s.error("Invalid UTF-8 encoding.")
+		} else if isIdentifierStart(r) {
+			s.ProcessIdentifier()
 		} else {
-			panic(fmt.Sprintf("Found unexpected character: %v", string(c)))
+			s.error(fmt.Sprintf("Unexpected character %q.", r))
 		}
 	}
 }
 
-func (s *Scanner) ProcessString() {
+// lineComment scans a comment running to the end of the line. One starting
+// with exactly three slashes is a doc comment.
+func (s *Scanner) lineComment() {
 	for s.Current < len(s.Source) && s.Source[s.Current] != '\n' {
-		if s.Source[s.Current] == '"' {
-			s.Current++
-			text := string(s.Source[s.Start+1 : s.Current-1])
-			s.Tokens = append(s.Tokens, NewToken(STRING, text, text, s.Line))
-      return
-		}
 		s.Current++
 	}
-  panic("Expected closing '\"' for a string")
+	text := strings.TrimRight(string(s.Source[s.Start:s.Current]), "\r")
+	if strings.HasPrefix(text, "///") && !strings.HasPrefix(text, "////") {
+		comment := s.token(DOC_COMMENT, text, nil)
+		s.Tokens = append(s.Tokens, comment)
+		s.Comments = append(s.Comments, comment)
+		return
+	}
+	s.Comments = append(s.Comments, s.token(COMMENT, text, nil))
 }
 
-func (s *Scanner) ProcessNumber() {
-	for s.Current < len(s.Source) && isDigit(s.Source[s.Current]) {
+// blockComment scans a `/* */' comment, which may span lines and contain
+// other block comments.
+func (s *Scanner) blockComment() {
+	depth := 1
+	for s.Current < len(s.Source) {
+		c := s.Source[s.Current]
 		s.Current++
+		switch {
+		case c == '\n':
+			s.newline()
+		case c == '/' && s.match('*'):
+			depth++
+		case c == '*' && s.match('/'):
+			depth--
+			if depth == 0 {
+				text := string(s.Source[s.Start:s.Current])
+				s.Comments = append(s.Comments, s.token(COMMENT, text, nil))
+				return
+			}
+		}
 	}
+	s.unterminated = true
+	s.error("Unterminated block comment.")
+}
+
+func (s *Scanner) newline() {
+	s.Line++
+	s.LineStart = s.Current
+}
 
-	if s.Source[s.Current] == '.' &&
-		s.Current+1 < len(s.Source) && isDigit(s.Source[s.Current+1]) {
+// ProcessString scans a string literal, which may span lines. The Lexeme
+// is the text between the quotes as written and the Literal the string it
+// stands for, with escape sequences replaced and \r\n line breaks read as
+// \n.
+//
+// A string with `${expr}' in it is scanned in segments: the text up to each
+// `${' is an INTERPOLATION token, followed by the tokens of expr, and the
+// text from the last `}' to the closing quote is a STRING. So
+// "a ${b} c ${d} e" becomes INTERPOLATION("a "), b, INTERPOLATION(" c "), d
+// and STRING(" e").
+func (s *Scanner) ProcessString() {
+	s.processString("")
+}
+
+// processString is ProcessString for a string whose text up to Current has
+// already been decoded into prefix.
+func (s *Scanner) processString(prefix string) {
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	for s.Current < len(s.Source) {
+		c := s.Source[s.Current]
 		s.Current++
-		for s.Current < len(s.Source) && isDigit(s.Source[s.Current]) {
+		switch c {
+		case '"':
+			text := string(s.Source[s.Start+1 : s.Current-1])
+			s.Tokens = append(s.Tokens, s.token(STRING, text, sb.String()))
+			return
+		case '\n':
+			s.newline()
+			sb.WriteByte(c)
+		case '\r':
+			if s.Current >= len(s.Source) || s.Source[s.Current] != '\n' {
+				sb.WriteByte(c)
+			}
+		case '\\':
+			s.escape(&sb)
+		case '$':
+			if !s.match('{') {
+				sb.WriteByte(c)
+				break
+			}
+			text := string(s.Source[s.Start+1 : s.Current-2])
+			segment := s.token(INTERPOLATION, text, sb.String())
+			s.Tokens = append(s.Tokens, segment)
+			s.interpolations = append(s.interpolations, interpolation{segment: segment})
+			return
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	// Inside an open `${', the quote was most likely meant to end the outer
+	// string; ScanTokens reports the interpolation instead.
+	s.unterminated = true
+	if len(s.interpolations) == 0 {
+		s.error("Unterminated string.")
+	}
+}
+
+// emptyInterpolation reports a `${}' with no expression in it, at the `${'.
+// The string is then scanned again from segment as if `${}' were text, so
+// the Parser does not trip over the missing expression as well.
+func (s *Scanner) emptyInterpolation(segment Token) {
+	s.interpolationError(segment, "Expected an expression between '${' and '}'.")
+	s.Tokens = s.Tokens[:len(s.Tokens)-1]
+	s.Start = segment.Offset
+	s.startLine, s.startLineStart = segment.Line, segment.Offset-segment.Column+1
+	s.processString(segment.Literal.(string) + "${}")
+}
+
+// interpolationError reports message at the `${' that ends segment.
+func (s *Scanner) interpolationError(segment Token, message string) {
+	end := segment.Span().End
+	token := NewToken(ILLEGAL, "${", nil, end.Line, end.Column-2, end.Offset-2, 2)
+	s.Errors = append(s.Errors, &ScanError{Token: token, Message: message})
+}
+
+var escapes = map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '"': '"', '\\': '\\', '0': 0, '$': '$'}
+
+// escape decodes the escape sequence after a backslash: one of \n, \t, \r,
+// \", \\, \0, \$, or \u{...} with up to six hex digits naming a code point.
+func (s *Scanner) escape(sb *strings.Builder) {
+	start := s.Current - 1
+	if s.Current >= len(s.Source) {
+		return
+	}
+	c := s.Source[s.Current]
+	s.Current++
+	if decoded, ok := escapes[c]; ok {
+		sb.WriteByte(decoded)
+		return
+	}
+	if c != 'u' {
+		s.errorAt(start, s.Current, fmt.Sprintf("Unknown escape sequence '\\%c'.", c))
+		if c == '\n' {
+			s.newline()
+		}
+		return
+	}
+
+	end := s.Current
+	for end < len(s.Source) && end-s.Current < 8 && s.Source[end] != '"' && s.Source[end] != '}' && s.Source[end] != '\n' {
+		end++
+	}
+	if end >= len(s.Source) || s.Source[s.Current] != '{' || s.Source[end] != '}' {
+		s.errorAt(start, s.Current, "Expected '{' and up to six hex digits and '}' after '\\u'.")
+		return
+	}
+	digits := string(s.Source[s.Current+1 : end])
+	s.Current = end + 1
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || digits == "" || len(digits) > 6 {
+		s.errorAt(start, s.Current, fmt.Sprintf("Invalid escape sequence '\\u{%s}'.", digits))
+		return
+	}
+	if code > unicode.MaxRune || (0xD800 <= code && code <= 0xDFFF) {
+		s.errorAt(start, s.Current, fmt.Sprintf("'\\u{%s}' is not a Unicode code point.", digits))
+		return
+	}
+	sb.WriteRune(rune(code))
+}
+
+// ProcessNumber scans a number literal: a decimal one with an optional
+// fraction and exponent, as in 12, 1.5 and 1e-9, or a hexadecimal or binary
+// one, as in 0xFF and 0b1010. Digits may be grouped with underscores, as in
+// 1_000_000. Letters and digits running on from the number, as in 0b12 or
+// 3px, are taken as part of it and reported.
+//
+// A number with a fraction or exponent is a float64 and any other an int64.
+func (s *Scanner) ProcessNumber() {
+	base, digits := 10, "decimal"
+	if s.Source[s.Start] == '0' {
+		switch s.peek() {
+		case 'x', 'X':
+			base, digits = 16, "hex"
+		case 'b', 'B':
+			base, digits = 2, "binary"
+		}
+	}
+
+	// The number is well formed up to rest; any letters and digits that
+	// run on from it are invalid.
+	first, exponent, float := s.Start, true, false
+	if base == 10 {
+		s.skip(isDigitOrSeparator)
+		if s.peek() == '.' && s.Current+1 < len(s.Source) && isDigit(s.Source[s.Current+1]) {
 			s.Current++
+			s.skip(isDigitOrSeparator)
+			float = true
 		}
+		if s.match('e') || s.match('E') {
+			float = true
+			if !s.match('+') {
+				s.match('-')
+			}
+			exponent = isDigit(s.peek())
+			s.skip(isDigitOrSeparator)
+		}
+	} else {
+		s.Current++
+		first = s.Current
 	}
+	rest := s.Current
+	s.skip(isNumberPart)
 
-	numStr := string(s.Source[s.Start:s.Current])
-	literal, err := strconv.ParseFloat(numStr, 64)
+	text := string(s.Source[s.Start:s.Current])
+	if !exponent {
+		s.numberError(fmt.Sprintf("Expected digits after the exponent in '%s'.", text))
+		return
+	}
+	if s.Current == first {
+		s.numberError(fmt.Sprintf("Expected %s digits after '%s'.", digits, text))
+		return
+	}
+	for n := rest; n < s.Current; n++ {
+		if c := s.Source[n]; c != '_' && !isDigitIn(c, base) {
+			s.numberError(fmt.Sprintf("Invalid character '%c' in %s number '%s'.", c, digits, text))
+			return
+		}
+	}
+	for n := first; n < s.Current; n++ {
+		if s.Source[n] == '_' && (n == first || n+1 == s.Current || !isDigitIn(s.Source[n-1], base) || !isDigitIn(s.Source[n+1], base)) {
+			s.numberError(fmt.Sprintf("Digit separator '_' must be between two digits in '%s'.", text))
+			return
+		}
+	}
+
+	var literal any
+	var err error
+	cleaned := strings.ReplaceAll(string(s.Source[first:s.Current]), "_", "")
+	if float {
+		literal, err = strconv.ParseFloat(cleaned, 64)
+	} else {
+		literal, err = strconv.ParseInt(cleaned, base, 64)
+	}
 	if err != nil {
-		fmt.Println("Error parsing number:", numStr)
+		s.numberError(fmt.Sprintf("Number '%s' is out of range.", text))
 		return
 	}
-	s.Tokens = append(s.Tokens, NewToken(NUMBER, numStr, literal, s.Line))
+	s.Tokens = append(s.Tokens, s.token(NUMBER, text, literal))
 }
 
+// numberError reports a malformed number literal. A NUMBER token is added
+// all the same, so the parser does not report the number missing as well.
+func (s *Scanner) numberError(message string) {
+	s.error(message)
+	text := string(s.Source[s.Start:s.Current])
+	s.Tokens = append(s.Tokens, s.token(NUMBER, text, int64(0)))
+}
+
+// ProcessIdentifier scans the rest of an identifier or keyword: letters,
+// digits and underscores, including non-ASCII letters and digits.
 func (s *Scanner) ProcessIdentifier() {
-	for isAlphanumeric(s.Source[s.Current]) {
-		s.Current++
+	for s.Current < len(s.Source) {
+		r, size := utf8.DecodeRune(s.Source[s.Current:])
+		if !isIdentifierPart(r) {
+			break
+		}
+		s.Current += size
 	}
 
 	str := string(s.Source[s.Start:s.Current])
 	keyword, ok := keywords[str]
 	if ok {
-		s.Tokens = append(s.Tokens, NewToken(keyword, str, nil, s.Line))
+		s.Tokens = append(s.Tokens, s.token(keyword, str, nil))
 	} else {
-		s.Tokens = append(s.Tokens, NewToken(IDENTIFIER, str, str, s.Line))
+		s.Tokens = append(s.Tokens, s.token(IDENTIFIER, str, str))
 	}
 }
 
 // helper
 func (s *Scanner) AddToken(tokenType TokenType, literal any) {
-	var text string
-	if literal == nil {
-		text = ""
-	} else {
-		text = string(s.Source[s.Start:s.Current])
+	text := string(s.Source[s.Start:s.Current])
+	s.Tokens = append(s.Tokens, s.token(tokenType, text, literal))
+}
+
+// peek returns the next byte without consuming it, or 0 at the end of the
+// source.
+func (s *Scanner) peek() byte {
+	if s.Current >= len(s.Source) {
+		return 0
+	}
+	return s.Source[s.Current]
+}
+
+// skip consumes bytes for as long as accept takes them.
+func (s *Scanner) skip(accept func(byte) bool) {
+	for s.Current < len(s.Source) && accept(s.Source[s.Current]) {
+		s.Current++
 	}
-	s.Tokens = append(s.Tokens, NewToken(tokenType, text, literal, s.Line))
+}
+
+func (s *Scanner) match(expected byte) bool {
+	if s.Current >= len(s.Source) || s.Source[s.Current] != expected {
+		return false
+	}
+	s.Current++
+	return true
+}
+
+// token builds a token for the source text between s.Start and s.Current.
+func (s *Scanner) token(tokenType TokenType, lexeme string, literal any) Token {
+	return NewToken(tokenType, lexeme, literal, s.startLine, s.column(), s.Start, s.Current-s.Start)
+}
+
+// column is the 1-based column of the token starting at s.Start. Columns
+// count bytes.
+func (s *Scanner) column() int {
+	return s.Start - s.startLineStart + 1
+}
+
+func (s *Scanner) error(message string) {
+	token := s.token(ILLEGAL, string(s.Source[s.Start:s.Current]), nil)
+	s.Errors = append(s.Errors, &ScanError{Token: token, Message: message})
+}
+
+// errorAt reports a problem with the part of the current token between
+// start and end, which must be on the current line.
+func (s *Scanner) errorAt(start, end int, message string) {
+	text := string(s.Source[start:end])
+	token := NewToken(ILLEGAL, text, nil, s.Line, start-s.LineStart+1, start, end-start)
+	s.Errors = append(s.Errors, &ScanError{Token: token, Message: message})
 }
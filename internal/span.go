@@ -0,0 +1,26 @@
+package internal
+
+// Position is a point in the source. Line and Column are 1-based; Offset is
+// the 0-based byte offset from the start of the source.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// Span is the source range [Start, End) covered by a token or syntax node.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// SpanBetween returns the span running from the start of first to the end
+// of last.
+func SpanBetween(first, last Span) Span {
+	return Span{first.Start, last.End}
+}
+
+// Contains reports whether offset falls inside the span.
+func (s Span) Contains(offset int) bool {
+	return s.Start.Offset <= offset && offset < s.End.Offset
+}
@@ -1,7 +1,11 @@
 package internal
 
+// Stmt is a statement or declaration. Range is the source the parser built
+// it from; statements produced by desugaring, like the loop a `for' becomes,
+// cover the whole construct they came from.
 type Stmt interface {
 	Apply(VisitorStmt) any
+	Span() Span
 }
 
 type VisitorStmt interface {
@@ -12,40 +16,66 @@ type VisitorStmt interface {
 	VisitIfStmt(IfStmt) any
 	VisitWhileStmt(WhileStmt) any
 	VisitFunctionStmt(FunctionStmt) any
+	VisitReturnStmt(ReturnStmt) any
+	VisitClassStmt(ClassStmt) any
 }
 
 type Expression struct {
-	Expr Expr
+	Expr  Expr
+	Range Span
 }
 
 type Print struct {
-	Expr Expr
+	Expr  Expr
+	Range Span
 }
 
 type VarDeclare struct {
-	Name        string
+	Name        Token
 	InitialExpr Expr
+	Range       Span
+	// Doc is the text of the `///' comments right above the declaration.
+	Doc string
 }
 
 type Block struct {
 	Statements []Stmt
+	Range      Span
 }
 
 type IfStmt struct {
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
+	Range      Span
 }
 
 type WhileStmt struct {
 	Condition Expr
 	Body      Stmt
+	Range     Span
 }
 
 type FunctionStmt struct {
 	Name   Token
 	Params []Token
-	Body   Stmt
+	Body   []Stmt
+	Range  Span
+	// Doc is the text of the `///' comments right above the declaration.
+	Doc string
+}
+
+type ClassStmt struct {
+	Name       Token
+	Superclass *Variable
+	Methods    []*FunctionStmt
+	Range      Span
+}
+
+type ReturnStmt struct {
+	Keyword Token
+	Value   Expr
+	Range   Span
 }
 
 func (stmt Expression) Apply(v VisitorStmt) any {
@@ -75,3 +105,47 @@ func (stmt WhileStmt) Apply(v VisitorStmt) any {
 func (stmt FunctionStmt) Apply(v VisitorStmt) any {
 	return v.VisitFunctionStmt(stmt)
 }
+
+func (stmt ReturnStmt) Apply(v VisitorStmt) any {
+	return v.VisitReturnStmt(stmt)
+}
+
+func (stmt ClassStmt) Apply(v VisitorStmt) any {
+	return v.VisitClassStmt(stmt)
+}
+
+func (stmt Expression) Span() Span {
+	return stmt.Range
+}
+
+func (stmt Print) Span() Span {
+	return stmt.Range
+}
+
+func (stmt VarDeclare) Span() Span {
+	return stmt.Range
+}
+
+func (stmt Block) Span() Span {
+	return stmt.Range
+}
+
+func (stmt IfStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt WhileStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt FunctionStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt ReturnStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt ClassStmt) Span() Span {
+	return stmt.Range
+}
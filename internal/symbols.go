@@ -0,0 +1,376 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type SymbolKind int
+
+const (
+	SymbolVariable SymbolKind = iota
+	SymbolParameter
+	SymbolFunction
+	SymbolClass
+	SymbolMethod
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymbolVariable:
+		return "variable"
+	case SymbolParameter:
+		return "parameter"
+	case SymbolFunction:
+		return "function"
+	case SymbolClass:
+		return "class"
+	case SymbolMethod:
+		return "method"
+	default:
+		return "unknown"
+	}
+}
+
+// Symbol is one declared name. Range covers the whole declaration and Scope
+// the region where the name can be referred to; Scope is the zero Span for
+// globals, which are visible everywhere. Methods are never in scope, they
+// are only reachable through properties. Reads counts the References that
+// read the value rather than assign to it.
+type Symbol struct {
+	Name       string
+	Kind       SymbolKind
+	Token      Token
+	Range      Span
+	Scope      Span
+	Detail     string
+	Doc        string
+	References []Token
+	Reads      int
+	Children   []*Symbol
+}
+
+func (s *Symbol) IsGlobal() bool {
+	return s.Scope == Span{}
+}
+
+// SymbolTable links every variable, function and class name in a program to
+// its declaration. It is built from the AST alone, so it also works for
+// programs that the Resolver would reject.
+type SymbolTable struct {
+	// Outline holds the top-level declarations; nested ones are Children.
+	Outline []*Symbol
+	Symbols []*Symbol
+
+	globals    map[string]*Symbol
+	unresolved []symbolReference
+	scopes     []symbolScope
+}
+
+type symbolReference struct {
+	name   Token
+	isRead bool
+}
+
+type symbolScope struct {
+	span    Span
+	names   map[string]*Symbol
+	outline *[]*Symbol
+}
+
+func NewSymbolTable(statements []Stmt) *SymbolTable {
+	table := &SymbolTable{globals: make(map[string]*Symbol)}
+	table.scopes = []symbolScope{{names: table.globals, outline: &table.Outline}}
+	table.statements(statements)
+
+	// Globals are late bound, so a function body may use a global that is
+	// declared further down the file.
+	for _, reference := range table.unresolved {
+		if symbol, ok := table.globals[reference.name.Lexeme]; ok {
+			symbol.use(reference.name, reference.isRead)
+		}
+	}
+	for _, symbol := range table.Symbols {
+		sort.Slice(symbol.References, func(a, b int) bool {
+			return symbol.References[a].Offset < symbol.References[b].Offset
+		})
+	}
+	table.unresolved, table.scopes = nil, nil
+	return table
+}
+
+// SymbolAt returns the symbol whose declaration or reference covers offset.
+// An offset just past the end of a name still counts, which is where the
+// cursor sits after typing it.
+func (t *SymbolTable) SymbolAt(offset int) *Symbol {
+	covers := func(token Token) bool {
+		return token.Offset <= offset && offset <= token.Offset+token.Length
+	}
+	for _, symbol := range t.Symbols {
+		if covers(symbol.Token) {
+			return symbol
+		}
+		for _, reference := range symbol.References {
+			if covers(reference) {
+				return symbol
+			}
+		}
+	}
+	return nil
+}
+
+// Visible returns the symbols that can be named at offset, innermost first.
+// A shadowed name is only returned once.
+func (t *SymbolTable) Visible(offset int) []*Symbol {
+	var locals []*Symbol
+	for _, symbol := range t.Symbols {
+		if symbol.Kind != SymbolMethod && !symbol.IsGlobal() &&
+			symbol.Scope.Contains(offset) && symbol.Token.Offset < offset {
+			locals = append(locals, symbol)
+		}
+	}
+	// Inner scopes start later, and later declarations shadow earlier ones.
+	sort.Slice(locals, func(a, b int) bool {
+		if locals[a].Scope.Start.Offset != locals[b].Scope.Start.Offset {
+			return locals[a].Scope.Start.Offset > locals[b].Scope.Start.Offset
+		}
+		return locals[a].Token.Offset > locals[b].Token.Offset
+	})
+
+	seen := make(map[string]bool)
+	var visible []*Symbol
+	for _, symbol := range locals {
+		if !seen[symbol.Name] {
+			seen[symbol.Name] = true
+			visible = append(visible, symbol)
+		}
+	}
+	for _, symbol := range t.Symbols {
+		if symbol.IsGlobal() && symbol.Kind != SymbolMethod && !seen[symbol.Name] {
+			seen[symbol.Name] = true
+			visible = append(visible, symbol)
+		}
+	}
+	return visible
+}
+
+func (t *SymbolTable) statements(statements []Stmt) {
+	for _, stmt := range statements {
+		stmt.Apply(t)
+	}
+}
+
+func (t *SymbolTable) beginScope(span Span, outline *[]*Symbol) {
+	t.scopes = append(t.scopes, symbolScope{span: span, names: make(map[string]*Symbol), outline: outline})
+}
+
+func (t *SymbolTable) endScope() {
+	t.scopes = t.scopes[:len(t.scopes)-1]
+}
+
+// declare records a symbol in the innermost scope. The first declaration of
+// a global wins, so redeclaring one does not hide its earlier references.
+func (t *SymbolTable) declare(name Token, kind SymbolKind, declaration Span, detail string) *Symbol {
+	scope := t.scopes[len(t.scopes)-1]
+	symbol := &Symbol{Name: name.Lexeme, Kind: kind, Token: name, Range: declaration, Scope: scope.span, Detail: detail}
+	t.Symbols = append(t.Symbols, symbol)
+	if scope.outline != nil && kind != SymbolParameter {
+		*scope.outline = append(*scope.outline, symbol)
+	}
+	if _, exists := scope.names[name.Lexeme]; !exists || len(t.scopes) > 1 {
+		scope.names[name.Lexeme] = symbol
+	}
+	return symbol
+}
+
+func (t *SymbolTable) reference(name Token, isRead bool) {
+	for i := len(t.scopes) - 1; i > 0; i-- {
+		if symbol, ok := t.scopes[i].names[name.Lexeme]; ok {
+			symbol.use(name, isRead)
+			return
+		}
+	}
+	t.unresolved = append(t.unresolved, symbolReference{name, isRead})
+}
+
+func (s *Symbol) use(name Token, isRead bool) {
+	s.References = append(s.References, name)
+	if isRead {
+		s.Reads++
+	}
+}
+
+func (t *SymbolTable) function(stmt FunctionStmt, symbol *Symbol) {
+	t.beginScope(stmt.Range, &symbol.Children)
+	for _, param := range stmt.Params {
+		t.declare(param, SymbolParameter, param.Span(), param.Lexeme)
+	}
+	t.statements(stmt.Body)
+	t.endScope()
+}
+
+func functionDetail(stmt FunctionStmt) string {
+	params := make([]string, len(stmt.Params))
+	for n, param := range stmt.Params {
+		params[n] = param.Lexeme
+	}
+	return fmt.Sprintf("%s(%s)", stmt.Name.Lexeme, strings.Join(params, ", "))
+}
+
+// statements
+
+func (t *SymbolTable) VisitExpression(stmt Expression) any {
+	stmt.Expr.Apply(t)
+	return nil
+}
+
+func (t *SymbolTable) VisitPrint(stmt Print) any {
+	stmt.Expr.Apply(t)
+	return nil
+}
+
+func (t *SymbolTable) VisitVarDeclare(stmt VarDeclare) any {
+	if stmt.InitialExpr != nil {
+		stmt.InitialExpr.Apply(t)
+	}
+	symbol := t.declare(stmt.Name, SymbolVariable, stmt.Range, stmt.Name.Lexeme)
+	symbol.Doc = stmt.Doc
+	return nil
+}
+
+func (t *SymbolTable) VisitBlock(stmt Block) any {
+	t.beginScope(stmt.Range, t.scopes[len(t.scopes)-1].outline)
+	t.statements(stmt.Statements)
+	t.endScope()
+	return nil
+}
+
+func (t *SymbolTable) VisitIfStmt(stmt IfStmt) any {
+	stmt.Condition.Apply(t)
+	stmt.ThenBranch.Apply(t)
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch.Apply(t)
+	}
+	return nil
+}
+
+func (t *SymbolTable) VisitWhileStmt(stmt WhileStmt) any {
+	stmt.Condition.Apply(t)
+	stmt.Body.Apply(t)
+	return nil
+}
+
+func (t *SymbolTable) VisitFunctionStmt(stmt FunctionStmt) any {
+	symbol := t.declare(stmt.Name, SymbolFunction, stmt.Range, functionDetail(stmt))
+	symbol.Doc = stmt.Doc
+	t.function(stmt, symbol)
+	return nil
+}
+
+func (t *SymbolTable) VisitReturnStmt(stmt ReturnStmt) any {
+	if stmt.Value != nil {
+		stmt.Value.Apply(t)
+	}
+	return nil
+}
+
+func (t *SymbolTable) VisitClassStmt(stmt ClassStmt) any {
+	detail := stmt.Name.Lexeme
+	if stmt.Superclass != nil {
+		detail += " < " + stmt.Superclass.Name.Lexeme
+	}
+	class := t.declare(stmt.Name, SymbolClass, stmt.Range, detail)
+	if stmt.Superclass != nil {
+		stmt.Superclass.Apply(t)
+	}
+
+	for _, method := range stmt.Methods {
+		symbol := &Symbol{
+			Name:   method.Name.Lexeme,
+			Kind:   SymbolMethod,
+			Token:  method.Name,
+			Range:  method.Range,
+			Scope:  stmt.Range,
+			Detail: functionDetail(*method),
+			Doc:    method.Doc,
+		}
+		t.Symbols = append(t.Symbols, symbol)
+		class.Children = append(class.Children, symbol)
+		t.function(*method, symbol)
+	}
+	return nil
+}
+
+// expressions
+
+func (t *SymbolTable) VisitBinaryExpr(expr *Binary) any {
+	expr.Left.Apply(t)
+	expr.Right.Apply(t)
+	return nil
+}
+
+func (t *SymbolTable) VisitUnaryExpr(expr *Unary) any {
+	expr.Right.Apply(t)
+	return nil
+}
+
+func (t *SymbolTable) VisitLiteralExpr(expr *Literal) any {
+	return nil
+}
+
+func (t *SymbolTable) VisitGroupingExpr(expr *Grouping) any {
+	expr.Inside.Apply(t)
+	return nil
+}
+
+func (t *SymbolTable) VisitVariableExpr(expr *Variable) any {
+	t.reference(expr.Name, true)
+	return nil
+}
+
+func (t *SymbolTable) VisitAssignmentExpr(expr *Assignment) any {
+	expr.Value.Apply(t)
+	t.reference(expr.Name, false)
+	return nil
+}
+
+func (t *SymbolTable) VisitCallExpr(expr *Call) any {
+	expr.Callee.Apply(t)
+	for _, arg := range expr.Arguments {
+		arg.Apply(t)
+	}
+	return nil
+}
+
+func (t *SymbolTable) VisitInterpolationExpr(expr *Interpolation) any {
+	for _, part := range expr.Expressions {
+		part.Apply(t)
+	}
+	return nil
+}
+
+func (t *SymbolTable) VisitLogicalExpr(expr *Logic) any {
+	expr.Left.Apply(t)
+	expr.Right.Apply(t)
+	return nil
+}
+
+func (t *SymbolTable) VisitGetExpr(expr *Get) any {
+	expr.Object.Apply(t)
+	return nil
+}
+
+func (t *SymbolTable) VisitSetExpr(expr *Set) any {
+	expr.Value.Apply(t)
+	expr.Object.Apply(t)
+	return nil
+}
+
+func (t *SymbolTable) VisitThisExpr(expr *This) any {
+	return nil
+}
+
+func (t *SymbolTable) VisitSuperExpr(expr *Super) any {
+	return nil
+}
@@ -1,15 +1,43 @@
 package internal
 
+import "strings"
+
+// Token is a lexeme together with where it was found: Offset and Length
+// are in bytes and cover the whole source text of the token, including the
+// quotes of a string.
 type Token struct {
 	TokenType TokenType
 	Lexeme    string
 	Literal   any
-	Line      uint16
+	Line      int
+	Column    int
+	Offset    int
+	Length    int
 }
 
-func NewToken(tokenType TokenType, lexeme string, literal any, line uint16) Token {
+func NewToken(tokenType TokenType, lexeme string, literal any, line, column, offset, length int) Token {
 	return Token{
-		tokenType, lexeme, literal, line,
+		tokenType, lexeme, literal, line, column, offset, length,
+	}
+}
+
+func (t Token) Span() Span {
+	end := Position{t.Line, t.Column + t.Length, t.Offset + t.Length}
+	if lines := strings.Count(t.Lexeme, "\n"); lines > 0 {
+		// A multi-line string ends on a later line, after the text
+		// following its last line break and the closing `"' or `${'.
+		end.Line += lines
+		end.Column = len(t.Lexeme) - strings.LastIndexByte(t.Lexeme, '\n')
+		switch t.TokenType {
+		case STRING:
+			end.Column++
+		case INTERPOLATION:
+			end.Column += 2
+		}
+	}
+	return Span{
+		Start: Position{t.Line, t.Column, t.Offset},
+		End:   end,
 	}
 }
 
@@ -28,6 +56,7 @@ const (
 	SEMICOLON
 	SLASH
 	STAR
+	PERCENT
 
 	// One or two character tokens
 	BANG
@@ -38,10 +67,14 @@ const (
 	GREATER_EQUAL
 	LESS
 	LESS_EQUAL
+	// `~/', integer division. `//' starts a comment.
+	TILDE_SLASH
 
 	// Literals
 	IDENTIFIER
 	STRING
+	// The part of a string up to a `${', see Scanner.ProcessString
+	INTERPOLATION
 	NUMBER
 
 	// Keywords
@@ -62,4 +95,14 @@ const (
 	VAR
 	WHILE
 	EOF
+
+	// Placeholder for characters the scanner could not turn into a token
+	ILLEGAL
+
+	// A `//' or `/* */' comment, kept in Scanner.Comments rather than in the
+	// token stream
+	COMMENT
+
+	// A `///' comment documenting the declaration after it
+	DOC_COMMENT
 )
@@ -22,7 +22,7 @@ var keywords = map[string]TokenType{
 }
 
 func (t Token) String() string {
-  return fmt.Sprintf("Token(%v, [ %v ], %v, %v)", t.TokenType, t.Lexeme, t.Literal, t.Line)
+	return fmt.Sprintf("Token(%v, [ %v ], %v, %v:%v)", t.TokenType, t.Lexeme, t.Literal, t.Line, t.Column)
 }
 
 func (tt TokenType) String() string {
@@ -49,6 +49,8 @@ func (tt TokenType) String() string {
 		return "SLASH"
 	case STAR:
 		return "STAR"
+	case PERCENT:
+		return "PERCENT"
 	case BANG:
 		return "BANG"
 	case BANG_EQUAL:
@@ -65,10 +67,14 @@ func (tt TokenType) String() string {
 		return "LESS"
 	case LESS_EQUAL:
 		return "LESS_EQUAL"
+	case TILDE_SLASH:
+		return "TILDE_SLASH"
 	case IDENTIFIER:
 		return "IDENTIFIER"
 	case STRING:
 		return "STRING"
+	case INTERPOLATION:
+		return "INTERPOLATION"
 	case NUMBER:
 		return "NUMBER"
 	case AND:
@@ -105,8 +111,13 @@ func (tt TokenType) String() string {
 		return "WHILE"
 	case EOF:
 		return "EOF"
+	case ILLEGAL:
+		return "ILLEGAL"
+	case COMMENT:
+		return "COMMENT"
+	case DOC_COMMENT:
+		return "DOC_COMMENT"
 	default:
 		return "UNKNOWN"
 	}
 }
-
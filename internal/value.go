@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ValueKind tells which Lox type a Value holds.
+type ValueKind uint8
+
+const (
+	NilKind ValueKind = iota
+	BoolKind
+	// IntKind and FloatKind are the two kinds of number: 64-bit integers,
+	// which integer literals such as 42 and 0xFF produce, and float64, which
+	// literals with a fraction or exponent produce.
+	IntKind
+	FloatKind
+	StringKind
+	// ObjectKind covers everything with identity: functions, classes and
+	// instances of both backends.
+	ObjectKind
+)
+
+// Value is a Lox runtime value. Numbers and booleans live in the struct
+// itself, so storing one in a variable or on the VM stack does not
+// allocate. The zero Value is nil.
+type Value struct {
+	kind ValueKind
+	// bits holds an int in two's complement, a float as its IEEE 754 bits
+	// and a boolean as 0 or 1.
+	bits uint64
+	// ref holds the string or the object.
+	ref any
+}
+
+func NilValue() Value {
+	return Value{}
+}
+
+func BoolValue(b bool) Value {
+	if b {
+		return Value{kind: BoolKind, bits: 1}
+	}
+	return Value{kind: BoolKind}
+}
+
+func IntValue(n int64) Value {
+	return Value{kind: IntKind, bits: uint64(n)}
+}
+
+func FloatValue(n float64) Value {
+	return Value{kind: FloatKind, bits: math.Float64bits(n)}
+}
+
+func StringValue(s string) Value {
+	return Value{kind: StringKind, ref: s}
+}
+
+// ObjectValue wraps a function, class or instance.
+func ObjectValue(object any) Value {
+	return Value{kind: ObjectKind, ref: object}
+}
+
+// LiteralValue converts the value of a Literal, as produced by the Scanner
+// and Parser.
+func LiteralValue(literal any) Value {
+	switch literal := literal.(type) {
+	case nil:
+		return Value{}
+	case bool:
+		return BoolValue(literal)
+	case int64:
+		return IntValue(literal)
+	case float64:
+		return FloatValue(literal)
+	case string:
+		return StringValue(literal)
+	default:
+		return ObjectValue(literal)
+	}
+}
+
+func (v Value) Kind() ValueKind { return v.kind }
+func (v Value) IsNil() bool     { return v.kind == NilKind }
+func (v Value) IsBool() bool    { return v.kind == BoolKind }
+func (v Value) IsInt() bool     { return v.kind == IntKind }
+func (v Value) IsFloat() bool   { return v.kind == FloatKind }
+func (v Value) IsNumber() bool  { return v.kind == IntKind || v.kind == FloatKind }
+func (v Value) IsString() bool  { return v.kind == StringKind }
+func (v Value) IsObject() bool  { return v.kind == ObjectKind }
+
+// AsBool, AsInt, AsFloat, AsString and AsObject return the zero value of
+// their type when v holds something else, so check the kind first.
+func (v Value) AsBool() bool {
+	return v.kind == BoolKind && v.bits != 0
+}
+
+func (v Value) AsInt() int64 {
+	if v.kind != IntKind {
+		return 0
+	}
+	return int64(v.bits)
+}
+
+// AsFloat also converts an int, to the nearest float64.
+func (v Value) AsFloat() float64 {
+	switch v.kind {
+	case IntKind:
+		return float64(int64(v.bits))
+	case FloatKind:
+		return math.Float64frombits(v.bits)
+	}
+	return 0
+}
+
+func (v Value) AsString() string {
+	s, _ := v.ref.(string)
+	return s
+}
+
+func (v Value) AsObject() any {
+	if v.kind != ObjectKind {
+		return nil
+	}
+	return v.ref
+}
+
+// Truthy follows Lox: nil and false are false, everything else is true.
+func (v Value) Truthy() bool {
+	switch v.kind {
+	case NilKind:
+		return false
+	case BoolKind:
+		return v.bits != 0
+	default:
+		return true
+	}
+}
+
+// Equal compares values of the same kind by value and objects by identity.
+// An int and a float are equal when they are the same number, exactly; any
+// other values of different kinds are not.
+func (v Value) Equal(other Value) bool {
+	if v.kind != other.kind {
+		switch {
+		case v.kind == IntKind && other.kind == FloatKind:
+			return intEqualsFloat(v.AsInt(), other.AsFloat())
+		case v.kind == FloatKind && other.kind == IntKind:
+			return intEqualsFloat(other.AsInt(), v.AsFloat())
+		}
+		return false
+	}
+	switch v.kind {
+	case NilKind:
+		return true
+	case BoolKind, IntKind:
+		return v.bits == other.bits
+	case FloatKind:
+		return v.AsFloat() == other.AsFloat()
+	default:
+		return v.ref == other.ref
+	}
+}
+
+func intEqualsFloat(i int64, f float64) bool {
+	// -2^63 <= f < 2^63 is the range int64 converts exactly.
+	return f == math.Trunc(f) && f >= math.MinInt64 && f < -math.MinInt64 && int64(f) == i
+}
+
+// String formats v the way print shows it: `nil', ints as they are, floats
+// always with a fraction or exponent, as in 1.0 and 1e+21, so they can be
+// told from ints, and strings without quotes.
+func (v Value) String() string {
+	switch v.kind {
+	case NilKind:
+		return "nil"
+	case BoolKind:
+		return strconv.FormatBool(v.bits != 0)
+	case IntKind:
+		return strconv.FormatInt(v.AsInt(), 10)
+	case FloatKind:
+		return formatFloat(v.AsFloat())
+	case StringKind:
+		return v.ref.(string)
+	default:
+		return fmt.Sprint(v.ref)
+	}
+}
+
+func formatFloat(n float64) string {
+	switch {
+	case math.IsInf(n, 1):
+		return "Infinity"
+	case math.IsInf(n, -1):
+		return "-Infinity"
+	case n == math.Trunc(n) && math.Abs(n) < 1e16:
+		return strconv.FormatFloat(n, 'f', 1, 64)
+	default:
+		return strconv.FormatFloat(n, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,485 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxFrames bounds the call depth of the VM, so runaway recursion becomes a
+// RuntimeError instead of exhausting memory.
+const maxFrames = 1024
+
+// vmClosure is a CompiledFunction together with the variables it captured.
+type vmClosure struct {
+	function *CompiledFunction
+	upvalues []*vmUpvalue
+}
+
+func (c *vmClosure) String() string {
+	return c.function.String()
+}
+
+// vmUpvalue is a captured variable. While the variable is still on the stack
+// the upvalue refers to its slot; once the variable goes out of scope the
+// value moves into the upvalue itself.
+type vmUpvalue struct {
+	slot   int
+	closed bool
+	value  Value
+}
+
+// vmClass holds its methods and those it inherited, which OpInherit copies
+// down from the superclass when the class is declared.
+type vmClass struct {
+	name    string
+	methods map[string]*vmClosure
+}
+
+func (c *vmClass) String() string {
+	return c.name
+}
+
+func (c *vmClass) methodNames() []string {
+	names := []string{}
+	for name := range c.methods {
+		names = append(names, name)
+	}
+	return names
+}
+
+type vmInstance struct {
+	class  *vmClass
+	fields map[string]Value
+}
+
+func (instance *vmInstance) String() string {
+	return instance.class.name + " instance"
+}
+
+type vmBoundMethod struct {
+	receiver *vmInstance
+	method   *vmClosure
+}
+
+func (m *vmBoundMethod) String() string {
+	return m.method.String()
+}
+
+type callFrame struct {
+	closure *vmClosure
+	ip      int
+	// base is the stack slot of the callee, which is local slot zero.
+	base int
+}
+
+// VM runs the bytecode made by the Compiler. It behaves like the
+// Interpreter: both print the same output and report the same runtime
+// errors for a program.
+type VM struct {
+	stack      []Value
+	frames     []callFrame
+	globals    map[string]Value
+	open       []*vmUpvalue
+	out        io.Writer
+	tracePrint bool
+	division   DivisionByZero
+}
+
+func NewVM() *VM {
+	vm := &VM{globals: make(map[string]Value)}
+	vm.globals["clock"] = ObjectValue(&GlobalClock{})
+	return vm
+}
+
+// SetOutput redirects print statements, which go to os.Stdout by default.
+func (vm *VM) SetOutput(out io.Writer) {
+	vm.out = out
+}
+
+// SetTracePrint prefixes everything print statements write with `>>'.
+func (vm *VM) SetTracePrint(enabled bool) {
+	vm.tracePrint = enabled
+}
+
+// SetDivisionByZero chooses what dividing by zero does. The default is a
+// RuntimeError.
+func (vm *VM) SetDivisionByZero(mode DivisionByZero) {
+	vm.division = mode
+}
+
+// Interpret runs a compiled script and returns the RuntimeError that stopped
+// it, if any. Globals are kept between calls.
+func (vm *VM) Interpret(script *CompiledFunction) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			runtimeErr, ok := r.(*RuntimeError)
+			if !ok {
+				panic(r)
+			}
+			err = runtimeErr
+			vm.stack, vm.frames, vm.open = vm.stack[:0], vm.frames[:0], nil
+		}
+	}()
+	closure := &vmClosure{function: script}
+	vm.push(ObjectValue(closure))
+	vm.call(closure, 0)
+	vm.run()
+	return nil
+}
+
+func (vm *VM) push(value Value) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() Value {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) Value {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// error builds a RuntimeError about the instruction being executed.
+func (vm *VM) error(message string) *RuntimeError {
+	frame := &vm.frames[len(vm.frames)-1]
+	chunk := &frame.closure.function.Chunk
+	return &RuntimeError{Token: chunk.TokenAt(frame.ip - 1), Message: message}
+}
+
+func (vm *VM) run() {
+	frame := &vm.frames[len(vm.frames)-1]
+	code := frame.closure.function.Chunk.Code
+	constants := frame.closure.function.Chunk.Constants
+
+	readByte := func() byte {
+		frame.ip++
+		return code[frame.ip-1]
+	}
+	readShort := func() int {
+		frame.ip += 2
+		return int(code[frame.ip-2])<<8 | int(code[frame.ip-1])
+	}
+	readString := func() string {
+		return constants[readShort()].AsString()
+	}
+	// switchFrame reloads the locals above after a call or return.
+	switchFrame := func() {
+		frame = &vm.frames[len(vm.frames)-1]
+		code = frame.closure.function.Chunk.Code
+		constants = frame.closure.function.Chunk.Constants
+	}
+
+	for {
+		op := OpCode(readByte())
+		switch op {
+		case OpConstant:
+			vm.push(constants[readShort()])
+		case OpNil:
+			vm.push(NilValue())
+		case OpTrue:
+			vm.push(BoolValue(true))
+		case OpFalse:
+			vm.push(BoolValue(false))
+		case OpPop:
+			vm.pop()
+
+		case OpGetLocal:
+			vm.push(vm.stack[frame.base+int(readByte())])
+		case OpSetLocal:
+			vm.stack[frame.base+int(readByte())] = vm.peek(0)
+		case OpGetGlobal:
+			name := readString()
+			value, ok := vm.globals[name]
+			if !ok {
+				panic(vm.undefinedVariable(name))
+			}
+			vm.push(value)
+		case OpDefineGlobal:
+			vm.globals[readString()] = vm.pop()
+		case OpSetGlobal:
+			name := readString()
+			if _, ok := vm.globals[name]; !ok {
+				panic(vm.undefinedVariable(name))
+			}
+			vm.globals[name] = vm.peek(0)
+		case OpGetUpvalue:
+			upvalue := frame.closure.upvalues[readByte()]
+			if upvalue.closed {
+				vm.push(upvalue.value)
+			} else {
+				vm.push(vm.stack[upvalue.slot])
+			}
+		case OpSetUpvalue:
+			upvalue := frame.closure.upvalues[readByte()]
+			if upvalue.closed {
+				upvalue.value = vm.peek(0)
+			} else {
+				vm.stack[upvalue.slot] = vm.peek(0)
+			}
+
+		case OpGetProperty:
+			name := readString()
+			instance, ok := vm.peek(0).AsObject().(*vmInstance)
+			if !ok {
+				panic(vm.error("Only instances have properties."))
+			}
+			if value, ok := instance.fields[name]; ok {
+				vm.pop()
+				vm.push(value)
+				break
+			}
+			vm.bindMethod(instance, instance.class, name)
+		case OpSetProperty:
+			name := readString()
+			instance, ok := vm.peek(1).AsObject().(*vmInstance)
+			if !ok {
+				panic(vm.error("Only instances have fields."))
+			}
+			value := vm.pop()
+			instance.fields[name] = value
+			vm.pop()
+			vm.push(value)
+		case OpGetSuper:
+			name := readString()
+			superclass := vm.pop().AsObject().(*vmClass)
+			vm.bindMethod(vm.peek(0).AsObject().(*vmInstance), superclass, name)
+
+		case OpEqual:
+			right, left := vm.pop(), vm.pop()
+			vm.push(BoolValue(left.Equal(right)))
+		case OpNotEqual:
+			right, left := vm.pop(), vm.pop()
+			vm.push(BoolValue(!left.Equal(right)))
+		case OpAdd:
+			right, left := vm.pop(), vm.pop()
+			if left.IsFloat() && right.IsFloat() {
+				vm.push(FloatValue(left.AsFloat() + right.AsFloat()))
+			} else {
+				vm.push(vm.check(binaryOperation(PLUS, left, right, vm.division)))
+			}
+		case OpSubtract, OpMultiply, OpDivide, OpIntDivide, OpModulo, OpGreater, OpGreaterEqual, OpLess, OpLessEqual:
+			right, left := vm.pop(), vm.pop()
+			vm.push(vm.check(binaryOperation(operatorTokens[op], left, right, vm.division)))
+		case OpNot:
+			vm.push(BoolValue(!vm.pop().Truthy()))
+		case OpNegate:
+			vm.push(vm.check(negate(vm.pop())))
+		case OpPrint:
+			vm.print(vm.pop())
+		case OpInterpolate:
+			count := int(readByte())
+			parts := vm.stack[len(vm.stack)-count:]
+			var sb strings.Builder
+			for _, part := range parts {
+				sb.WriteString(part.String())
+			}
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(StringValue(sb.String()))
+
+		case OpJump:
+			offset := readShort()
+			frame.ip += offset
+		case OpJumpIfFalse:
+			offset := readShort()
+			if !vm.peek(0).Truthy() {
+				frame.ip += offset
+			}
+		case OpLoop:
+			offset := readShort()
+			frame.ip -= offset
+		case OpCall:
+			argc := int(readByte())
+			vm.callValue(vm.peek(argc), argc)
+			switchFrame()
+
+		case OpClosure:
+			function := constants[readShort()].AsObject().(*CompiledFunction)
+			closure := &vmClosure{function: function, upvalues: make([]*vmUpvalue, function.UpvalueCount)}
+			for n := range closure.upvalues {
+				isLocal, index := readByte() == 1, int(readByte())
+				if isLocal {
+					closure.upvalues[n] = vm.captureUpvalue(frame.base + index)
+				} else {
+					closure.upvalues[n] = frame.closure.upvalues[index]
+				}
+			}
+			vm.push(ObjectValue(closure))
+		case OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OpReturn:
+			result := vm.pop()
+			vm.closeUpvalues(frame.base)
+			vm.stack = vm.stack[:frame.base]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == 0 {
+				return
+			}
+			vm.push(result)
+			switchFrame()
+
+		case OpClass:
+			vm.push(ObjectValue(&vmClass{name: readString(), methods: make(map[string]*vmClosure)}))
+		case OpInherit:
+			superclass, ok := vm.peek(1).AsObject().(*vmClass)
+			if !ok {
+				panic(vm.error("Superclass must be a class."))
+			}
+			subclass := vm.pop().AsObject().(*vmClass)
+			for name, method := range superclass.methods {
+				subclass.methods[name] = method
+			}
+		case OpMethod:
+			name := readString()
+			method := vm.pop().AsObject().(*vmClosure)
+			vm.peek(0).AsObject().(*vmClass).methods[name] = method
+
+		default:
+			panic(vm.error(fmt.Sprintf("Unknown instruction %v.", op)))
+		}
+	}
+}
+
+// operatorTokens maps the VM's arithmetic instructions to the operators
+// binaryOperation takes.
+var operatorTokens = map[OpCode]TokenType{
+	OpSubtract:     MINUS,
+	OpMultiply:     STAR,
+	OpDivide:       SLASH,
+	OpIntDivide:    TILDE_SLASH,
+	OpModulo:       PERCENT,
+	OpGreater:      GREATER,
+	OpGreaterEqual: GREATER_EQUAL,
+	OpLess:         LESS,
+	OpLessEqual:    LESS_EQUAL,
+}
+
+// check passes on the result of an operation, or raises the RuntimeError
+// it asks for.
+func (vm *VM) check(result Value, message string) Value {
+	if message != "" {
+		panic(vm.error(message))
+	}
+	return result
+}
+
+func (vm *VM) print(value Value) {
+	out := vm.out
+	if out == nil {
+		out = os.Stdout
+	}
+	if vm.tracePrint {
+		fmt.Fprintln(out, ">>", value)
+	} else {
+		fmt.Fprintln(out, value)
+	}
+}
+
+func (vm *VM) undefinedVariable(name string) *RuntimeError {
+	err := vm.error("Undefined variable '" + name + "'.")
+	names := []string{}
+	for global := range vm.globals {
+		names = append(names, global)
+	}
+	err.Suggestion = closestName(name, names)
+	return err
+}
+
+// bindMethod replaces the instance on top of the stack with its method name
+// from class.
+func (vm *VM) bindMethod(instance *vmInstance, class *vmClass, name string) {
+	method, ok := class.methods[name]
+	if !ok {
+		err := vm.error("Undefined property '" + name + "'.")
+		candidates := class.methodNames()
+		if class == instance.class {
+			for field := range instance.fields {
+				candidates = append(candidates, field)
+			}
+		}
+		err.Suggestion = closestName(name, candidates)
+		panic(err)
+	}
+	vm.pop()
+	vm.push(ObjectValue(&vmBoundMethod{receiver: instance, method: method}))
+}
+
+// callValue calls the callee below the argc arguments on top of the stack.
+// Closures get a new frame; everything else completes right away and leaves
+// its result in place of the callee and arguments.
+func (vm *VM) callValue(callee Value, argc int) {
+	base := len(vm.stack) - argc - 1
+	switch callee := callee.AsObject().(type) {
+	case *vmClosure:
+		vm.call(callee, argc)
+	case *vmBoundMethod:
+		vm.stack[base] = ObjectValue(callee.receiver)
+		vm.call(callee.method, argc)
+	case *vmClass:
+		vm.stack[base] = ObjectValue(&vmInstance{class: callee, fields: make(map[string]Value)})
+		// Arity is checked here so the error names the class, as it does in
+		// the Interpreter, rather than its initializer.
+		arity := 0
+		initializer, ok := callee.methods["init"]
+		if ok {
+			arity = initializer.function.Arity
+		}
+		if arity != argc {
+			panic(vm.error(arityMessage(callee, arity, argc)))
+		}
+		if ok {
+			vm.call(initializer, argc)
+		}
+	case LoxCallable:
+		if callee.Arity() != argc {
+			panic(vm.error(arityMessage(callee, callee.Arity(), argc)))
+		}
+		args := append([]Value{}, vm.stack[base+1:]...)
+		result := callee.Call(nil, &args)
+		vm.stack = vm.stack[:base]
+		vm.push(result)
+	default:
+		panic(vm.error("Can only call functions and classes."))
+	}
+}
+
+func (vm *VM) call(closure *vmClosure, argc int) {
+	if closure.function.Arity != argc {
+		panic(vm.error(arityMessage(closure, closure.function.Arity, argc)))
+	}
+	if len(vm.frames) == maxFrames {
+		panic(vm.error("Stack overflow."))
+	}
+	vm.frames = append(vm.frames, callFrame{closure: closure, base: len(vm.stack) - argc - 1})
+}
+
+// captureUpvalue returns the open upvalue for slot, so closures capturing
+// the same variable share it.
+func (vm *VM) captureUpvalue(slot int) *vmUpvalue {
+	for _, upvalue := range vm.open {
+		if upvalue.slot == slot {
+			return upvalue
+		}
+	}
+	upvalue := &vmUpvalue{slot: slot}
+	vm.open = append(vm.open, upvalue)
+	return upvalue
+}
+
+// closeUpvalues moves the variables from slot up off the stack and into
+// their upvalues.
+func (vm *VM) closeUpvalues(slot int) {
+	open := vm.open[:0]
+	for _, upvalue := range vm.open {
+		if upvalue.slot >= slot {
+			upvalue.value, upvalue.closed = vm.stack[upvalue.slot], true
+		} else {
+			open = append(open, upvalue)
+		}
+	}
+	vm.open = open
+}
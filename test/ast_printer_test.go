@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	gx "golox/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, source string) []gx.Stmt {
+	t.Helper()
+	scanner := gx.NewScanner([]byte(source))
+	tokens, scanErrors := scanner.ScanTokens()
+	require.Empty(t, scanErrors)
+	parser := gx.NewParser(tokens)
+	statements, parseErrors := parser.Parse()
+	require.Empty(t, parseErrors)
+	return statements
+}
+
+func TestAstPrinter_SExpressions(t *testing.T) {
+	statements := parse(t, `
+var a = (1 + 2) * -3;
+if (a > 0 and !false) print "yes"; else a = nil;
+fun f(x, y) { return x.y; }
+`)
+	assert.Equal(t,
+		"(var a (* (group (+ 1 2)) (- 3)))\n"+
+			"(if-else (and (> a 0) (! false)) (print \"yes\") (; (= a nil)))\n"+
+			"(fun f (x y) (return (. x y)))\n",
+		gx.AstPrinter{}.Print(statements))
+}
+
+func TestAstPrinter_Interpolation(t *testing.T) {
+	statements := parse(t, `print "a ${b + 1} c ${"d ${e}"}";`)
+	assert.Equal(t, "(print (str \"a \" (+ b 1) \" c \" (str \"d \" e)))\n", gx.AstPrinter{}.Print(statements))
+
+	interpolation := statements[0].(gx.Print).Expr.(*gx.Interpolation)
+	assert.Len(t, interpolation.Segments, 3)
+	assert.Len(t, interpolation.Expressions, 2)
+}
+
+func TestAstJSON_Marshal(t *testing.T) {
+	data, err := gx.AstJSON{}.Marshal(parse(t, "print a + 1;\n"))
+	require.NoError(t, err)
+
+	var nodes []map[string]any
+	require.NoError(t, json.Unmarshal(data, &nodes))
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "Print", nodes[0]["node"])
+	binary := nodes[0]["expression"].(map[string]any)
+	assert.Equal(t, "Binary", binary["node"])
+	assert.Equal(t, "+", binary["operator"].(map[string]any)["lexeme"])
+	assert.Equal(t, 1.0, binary["right"].(map[string]any)["value"])
+}
+
+func TestInterpreter_TracePrint(t *testing.T) {
+	var out bytes.Buffer
+	interpreter := gx.NewInterpreter()
+	interpreter.SetOutput(&out)
+	statements := parse(t, "print 1;\n")
+
+	require.NoError(t, interpreter.Interpret(statements))
+	interpreter.SetTracePrint(true)
+	require.NoError(t, interpreter.Interpret(statements))
+	assert.Equal(t, "1\n>> 1\n", out.String())
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	gx "golox/internal"
+	"io"
+	"testing"
+)
+
+var benchmarkPrograms = []struct {
+	name   string
+	source string
+}{
+	{"fib", `
+fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+print fib(20);
+`},
+	{"loops", `
+var sum = 0;
+for (var i = 0; i < 200; i = i + 1) {
+  for (var j = 0; j < 200; j = j + 1) {
+    sum = sum + i * j;
+  }
+}
+print sum;
+`},
+	{"strings", `
+var text = "";
+for (var i = 0; i < 2000; i = i + 1) {
+  text = text + "x";
+}
+print text == "";
+`},
+}
+
+// BenchmarkBackends runs each program on both backends. Scanning and parsing
+// happen once up front; the tree timings include resolving, which binds
+// variables for one Interpreter, and the VM timings include compilation.
+func BenchmarkBackends(b *testing.B) {
+	for _, program := range benchmarkPrograms {
+		scanner := gx.NewScanner([]byte(program.source))
+		tokens, _ := scanner.ScanTokens()
+		parser := gx.NewParser(tokens)
+		statements, _ := parser.Parse()
+
+		b.Run(program.name+"/tree", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				interpreter := gx.NewInterpreter()
+				interpreter.SetOutput(io.Discard)
+				resolver := gx.NewResolver(interpreter)
+				resolver.Resolve(statements)
+				if err := interpreter.Interpret(statements); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(program.name+"/vm", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				script, _ := gx.Compile(statements)
+				vm := gx.NewVM()
+				vm.SetOutput(io.Discard)
+				if err := vm.Interpret(script); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
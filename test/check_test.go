@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	gx "golox/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck_NoProblems(t *testing.T) {
+	source := []byte("var a = 1;\nprint a;\n")
+	errs := gx.Check(source)
+	assert.Empty(t, errs)
+
+	data, err := gx.DiagnosticPrinter{File: "ok.gx", Source: source}.JSON(errs)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[]`, string(data))
+}
+
+func TestCheck_DoesNotExecute(t *testing.T) {
+	assert.Empty(t, gx.Check([]byte("print 1 / 0;\nprint undefined;\n")))
+}
+
+func TestCheck_StopsBeforeResolvingBrokenSyntax(t *testing.T) {
+	errs := gx.Check([]byte("{ var a = 1; var a = 2; }\nprint 1 +;\n"))
+	require.Len(t, errs, 1)
+	assert.IsType(t, &gx.ParseError{}, errs[0])
+}
+
+func TestCheck_JSON(t *testing.T) {
+	source := []byte("{\n  var b = 2;\n  var b = 3;\n  print b;\n}\n")
+	data, err := gx.DiagnosticPrinter{File: "dup.gx", Source: source}.JSON(gx.Check(source))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{
+		"severity": "error",
+		"code": "resolve-error",
+		"message": "Already a variable with this name in this scope.",
+		"file": "dup.gx",
+		"range": {
+			"start": {"line": 3, "column": 7, "offset": 21},
+			"end": {"line": 3, "column": 8, "offset": 22}
+		},
+		"related": [{
+			"message": "'b' was first declared at 2:7.",
+			"file": "dup.gx",
+			"range": {
+				"start": {"line": 2, "column": 7, "offset": 8},
+				"end": {"line": 2, "column": 8, "offset": 9}
+			}
+		}]
+	}]`, string(data))
+}
+
+func TestCheck_JSONCodes(t *testing.T) {
+	source := []byte("var a = @;\nprint a +;\n")
+	data, err := gx.DiagnosticPrinter{File: "bad.gx", Source: source}.JSON(gx.Check(source))
+	require.NoError(t, err)
+
+	var diagnostics []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Range   struct {
+			Start struct{ Line, Column int }
+		} `json:"range"`
+	}
+	require.NoError(t, json.Unmarshal(data, &diagnostics))
+	require.Len(t, diagnostics, 3)
+	assert.Equal(t, "scan-error", diagnostics[0].Code)
+	assert.Equal(t, "parse-error", diagnostics[1].Code)
+	assert.Equal(t, 2, diagnostics[2].Range.Start.Line)
+	assert.Equal(t, 10, diagnostics[2].Range.Start.Column)
+}
+
+func TestCheck_JSONSuggestion(t *testing.T) {
+	_, err := runProgram(t, "var count = 1;\nprint conut;\n")
+	require.Error(t, err)
+
+	data, jsonErr := gx.DiagnosticPrinter{File: "typo.gx"}.JSON([]error{err})
+	require.NoError(t, jsonErr)
+	assert.Contains(t, string(data), `"code": "runtime-error"`)
+	assert.Contains(t, string(data), `"suggestion": "count"`)
+}
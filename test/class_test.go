@@ -0,0 +1,208 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClass_PrintClassAndInstance(t *testing.T) {
+	out, err := runProgram(t, `
+class Bagel {}
+print Bagel;
+print Bagel();
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bagel\nBagel instance\n", out)
+}
+
+func TestClass_Fields(t *testing.T) {
+	out, err := runProgram(t, `
+class Point {}
+var p = Point();
+p.x = 1;
+p.y = p.x + 2;
+print p.x;
+print p.y;
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n3\n", out)
+}
+
+func TestClass_MethodsAndThis(t *testing.T) {
+	out, err := runProgram(t, `
+class Cake {
+  taste() {
+    var adjective = "delicious";
+    print "The " + this.flavor + " cake is " + adjective + "!";
+  }
+}
+
+var cake = Cake();
+cake.flavor = "German chocolate";
+cake.taste();
+
+var taste = cake.taste;
+cake.flavor = "lemon";
+taste();
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "The German chocolate cake is delicious!\nThe lemon cake is delicious!\n", out)
+}
+
+func TestClass_Initializer(t *testing.T) {
+	out, err := runProgram(t, `
+class Counter {
+  init(start) {
+    this.count = start;
+    if (start < 0) return;
+    this.count = this.count + 1;
+  }
+
+  next() {
+    this.count = this.count + 1;
+    return this.count;
+  }
+}
+
+var c = Counter(1);
+print c.next();
+print c.next();
+print Counter(-5).count;
+print c.init(10) == c;
+print c.count;
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "3\n4\n-5\ntrue\n11\n", out)
+}
+
+func TestClass_InitializerArity(t *testing.T) {
+	_, err := runProgram(t, `
+class Pair {
+  init(a, b) {
+    this.a = a;
+    this.b = b;
+  }
+}
+Pair(1);
+`)
+	assert.EqualError(t, err, "8:7: Pair expected 2 arguments but got 1.")
+}
+
+func TestClass_RuntimeErrors(t *testing.T) {
+	_, err := runProgram(t, "class A {}\nprint A().missing;\n")
+	assert.EqualError(t, err, "2:11: Undefined property 'missing'.")
+
+	_, err = runProgram(t, "var a = 1;\nprint a.field;\n")
+	assert.EqualError(t, err, "2:9: Only instances have properties.")
+
+	_, err = runProgram(t, "var a = \"str\";\na.field = 1;\n")
+	assert.EqualError(t, err, "2:3: Only instances have fields.")
+}
+
+func TestClass_StaticErrors(t *testing.T) {
+	assert.Equal(t,
+		[]string{"1:7: at 'this': Can't use 'this' outside of a class."},
+		resolveErrors(t, "print this;\n"))
+
+	assert.Equal(t,
+		[]string{"3:5: at 'return': Can't return a value from an initializer."},
+		resolveErrors(t, "class A {\n  init() {\n    return 1;\n  }\n}\n"))
+}
+
+func TestClass_InheritsMethods(t *testing.T) {
+	out, err := runProgram(t, `
+class Doughnut {
+  cook() {
+    print "Fry until golden brown.";
+  }
+}
+
+class BostonCream < Doughnut {}
+
+BostonCream().cook();
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "Fry until golden brown.\n", out)
+}
+
+func TestClass_SuperCalls(t *testing.T) {
+	out, err := runProgram(t, `
+class A {
+  method() {
+    print "A method";
+  }
+  describe() {
+    return "A";
+  }
+}
+
+class B < A {
+  method() {
+    print "B method";
+  }
+
+  test() {
+    super.method();
+  }
+
+  describe() {
+    return "B extends " + super.describe();
+  }
+}
+
+class C < B {}
+
+C().test();
+print C().describe();
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "A method\nB extends A\n", out)
+}
+
+func TestClass_SuperInitializer(t *testing.T) {
+	out, err := runProgram(t, `
+class Shape {
+  init(name) {
+    this.name = name;
+  }
+}
+
+class Square < Shape {
+  init(side) {
+    super.init("square");
+    this.side = side;
+  }
+
+  area() {
+    return this.side * this.side;
+  }
+}
+
+var s = Square(3);
+print s.name;
+print s.area();
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "square\n9\n", out)
+}
+
+func TestClass_InheritanceErrors(t *testing.T) {
+	assert.Equal(t,
+		[]string{"1:14: at 'Oops': A class can't inherit from itself."},
+		resolveErrors(t, "class Oops < Oops {}\n"))
+
+	assert.Equal(t,
+		[]string{"1:7: at 'super': Can't use 'super' outside of a class."},
+		resolveErrors(t, "print super.method;\n"))
+
+	assert.Equal(t,
+		[]string{"3:5: at 'super': Can't use 'super' in a class with no superclass."},
+		resolveErrors(t, "class Base {\n  method() {\n    super.method();\n  }\n}\n"))
+
+	_, err := runProgram(t, "var NotAClass = \"nope\";\nclass Sub < NotAClass {}\n")
+	assert.EqualError(t, err, "2:13: Superclass must be a class.")
+
+	_, err = runProgram(t, "class A {}\nclass B < A {\n  m() {\n    super.missing();\n  }\n}\nB().m();\n")
+	assert.EqualError(t, err, "4:11: Undefined property 'missing'.")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildGolox builds cmd/golox into a temporary directory and returns the
+// path of the binary.
+func buildGolox(t *testing.T) string {
+	t.Helper()
+	binary := filepath.Join(t.TempDir(), "golox")
+	build := exec.Command("go", "build", "-o", binary, "../cmd/golox")
+	output, err := build.CombinedOutput()
+	require.NoError(t, err, string(output))
+	return binary
+}
+
+func TestCli_CompileAndRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the golox binary")
+	}
+	golox := buildGolox(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.gx")
+	require.NoError(t, os.WriteFile(script, []byte("print 1 + 2;\n"), 0o644))
+
+	// Flags are accepted before and after the script path.
+	for _, args := range [][]string{
+		{"compile", script, "-o", filepath.Join(dir, "after.gxc")},
+		{"compile", "-o", filepath.Join(dir, "before.gxc"), script},
+	} {
+		output, err := exec.Command(golox, args...).CombinedOutput()
+		require.NoError(t, err, string(output))
+		assert.Empty(t, string(output))
+	}
+
+	for _, compiled := range []string{"after.gxc", "before.gxc"} {
+		output, err := exec.Command(golox, "run", filepath.Join(dir, compiled)).CombinedOutput()
+		require.NoError(t, err, string(output))
+		assert.Equal(t, "3\n", string(output))
+	}
+
+	err := exec.Command(golox, "compile", script, "extra.gx").Run()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 64, exitErr.ExitCode())
+}
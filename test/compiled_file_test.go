@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	gx "golox/internal"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCompiled(t *testing.T, source string) []byte {
+	t.Helper()
+	script, errs := compileProgram(t, source)
+	require.Empty(t, errs)
+	var buffer bytes.Buffer
+	_, err := gx.CompiledFile{SourceFile: "script.gx", Script: script}.WriteTo(&buffer)
+	require.NoError(t, err)
+	return buffer.Bytes()
+}
+
+// A program loaded from a .gxc file behaves exactly like the one compiled
+// from source, including the positions in runtime errors.
+func TestCompiledFile_RoundTrip(t *testing.T) {
+	files, err := filepath.Glob("testdata/conformance/*.gx")
+	require.NoError(t, err)
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			require.NoError(t, err)
+			expected, err := os.ReadFile(strings.TrimSuffix(file, ".gx") + ".expected")
+			require.NoError(t, err)
+
+			compiled, err := gx.ReadCompiledFile(bytes.NewReader(writeCompiled(t, string(source))))
+			require.NoError(t, err)
+			assert.Equal(t, "script.gx", compiled.SourceFile)
+
+			var out bytes.Buffer
+			vm := gx.NewVM()
+			vm.SetOutput(&out)
+			if err := vm.Interpret(compiled.Script); err != nil {
+				out.WriteString("runtime error: " + err.Error() + "\n")
+			}
+			assert.Equal(t, string(expected), out.String())
+		})
+	}
+}
+
+func TestCompiledFile_Rejected(t *testing.T) {
+	data := writeCompiled(t, "print 1;")
+
+	_, err := gx.ReadCompiledFile(strings.NewReader("print 1;"))
+	assert.EqualError(t, err, "not a compiled golox file")
+
+	wrongVersion := bytes.Clone(data)
+	wrongVersion[5]++
+	_, err = gx.ReadCompiledFile(bytes.NewReader(wrongVersion))
+	assert.ErrorContains(t, err, fmt.Sprintf("compiled file version %d is not supported", gx.CompiledFileVersion+1))
+
+	corrupt := bytes.Clone(data)
+	corrupt[len(corrupt)-1] ^= 0xff
+	_, err = gx.ReadCompiledFile(bytes.NewReader(corrupt))
+	assert.EqualError(t, err, "compiled file is corrupt: checksum mismatch")
+
+	_, err = gx.ReadCompiledFile(bytes.NewReader(data[:len(data)-1]))
+	assert.EqualError(t, err, "compiled file is corrupt: checksum mismatch")
+}
+
+func TestDisassemble(t *testing.T) {
+	source := "fun add(a, b) {\n  return a + b;\n}\nprint add(1, 2);\n"
+	script, errs := compileProgram(t, source)
+	require.Empty(t, errs)
+
+	expected := `== <script> ==
+-- 1 | fun add(a, b) {
+0000    1 OP_CLOSURE          0 <fn add>
+0003    | OP_DEFINE_GLOBAL    1 'add'
+-- 4 | print add(1, 2);
+0006    4 OP_GET_GLOBAL       1 'add'
+0009    | OP_CONSTANT         2 '1'
+0012    | OP_CONSTANT         3 '2'
+0015    | OP_CALL             2
+0017    | OP_PRINT
+0018    | OP_NIL
+0019    | OP_RETURN
+
+== <fn add> ==
+-- 2 |   return a + b;
+0000    2 OP_GET_LOCAL        1
+0002    | OP_GET_LOCAL        2
+0004    | OP_ADD
+0005    | OP_RETURN
+-- 1 | fun add(a, b) {
+0006    1 OP_NIL
+0007    | OP_RETURN
+`
+	assert.Equal(t, expected, gx.Disassemble(script, []byte(source)))
+	assert.NotContains(t, gx.Disassemble(script, nil), "--")
+}
+
+func TestDisassemble_Jumps(t *testing.T) {
+	script, errs := compileProgram(t, "var n = 0;\nwhile (n < 1) n = n + 1;\nfun f() { var c = 1; fun g() { return c; } }")
+	require.Empty(t, errs)
+	listing := gx.Disassemble(script, nil)
+	assert.Contains(t, listing, "OP_JUMP_IF_FALSE   13 -> 31\n")
+	assert.Contains(t, listing, "OP_LOOP            28 -> 6\n")
+	assert.Contains(t, listing, "|                     local 1\n")
+}
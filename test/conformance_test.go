@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	gx "golox/internal"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backends run a program that passed the static checks and return what it
+// printed and the runtime error that stopped it, if any.
+var backends = map[string]func(t *testing.T, source string) (string, error){
+	"tree": runProgram,
+	"vm":   runProgramVM,
+}
+
+// compileProgram scans, parses, resolves and compiles source. Errors before
+// compilation fail the test.
+func compileProgram(t *testing.T, source string) (*gx.CompiledFunction, []error) {
+	t.Helper()
+	scanner := gx.NewScanner([]byte(source))
+	tokens, scanErrors := scanner.ScanTokens()
+	require.Empty(t, scanErrors)
+	parser := gx.NewParser(tokens)
+	statements, parseErrors := parser.Parse()
+	require.Empty(t, parseErrors)
+	resolver := gx.NewResolver(gx.NewInterpreter())
+	require.Empty(t, resolver.Resolve(statements))
+	return gx.Compile(statements)
+}
+
+// runProgramVM is runProgram for the bytecode VM.
+func runProgramVM(t *testing.T, source string) (string, error) {
+	t.Helper()
+	script, errs := compileProgram(t, source)
+	require.Empty(t, errs)
+
+	var out bytes.Buffer
+	vm := gx.NewVM()
+	vm.SetOutput(&out)
+	err := vm.Interpret(script)
+	return out.String(), err
+}
+
+// Each testdata/conformance/<name>.gx program is run on every backend, which
+// must print <name>.expected. A program stopped by a runtime error expects
+// the error on a last line, `runtime error: <line>:<column>: <message>'.
+func TestConformance(t *testing.T) {
+	files, err := filepath.Glob("testdata/conformance/*.gx")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		require.NoError(t, err)
+		expected, err := os.ReadFile(strings.TrimSuffix(file, ".gx") + ".expected")
+		require.NoError(t, err)
+
+		for name, run := range backends {
+			t.Run(filepath.Base(file)+"/"+name, func(t *testing.T) {
+				output, err := run(t, string(source))
+				if err != nil {
+					output += "runtime error: " + err.Error() + "\n"
+				}
+				assert.Equal(t, string(expected), output)
+			})
+		}
+	}
+}
+
+func TestVM_RuntimeErrorSuggestion(t *testing.T) {
+	_, err := runProgramVM(t, "var count = 1;\nprint cont;")
+	require.Error(t, err)
+	assert.Equal(t, "count", err.(*gx.RuntimeError).Suggestion)
+
+	_, err = runProgramVM(t, "class A { method() {} }\nA().metod();")
+	require.Error(t, err)
+	assert.Equal(t, "method", err.(*gx.RuntimeError).Suggestion)
+}
+
+func TestVM_TracePrint(t *testing.T) {
+	script, errs := compileProgram(t, "print 1;")
+	require.Empty(t, errs)
+
+	var out bytes.Buffer
+	vm := gx.NewVM()
+	vm.SetOutput(&out)
+	vm.SetTracePrint(true)
+	require.NoError(t, vm.Interpret(script))
+	assert.Equal(t, ">> 1\n", out.String())
+}
+
+func TestCompile_TooManyArguments(t *testing.T) {
+	args := strings.Repeat("1, ", 255) + "1"
+	_, errs := compileProgram(t, "fun f() {}\nf("+args+");")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "2:769: at ')': Can't have more than 255 arguments.", errs[0].Error())
+	assert.Equal(t, "compile-error", gx.NewDiagnostic(errs[0]).Code)
+}
+
+func TestVM_DivisionByZeroIEEE(t *testing.T) {
+	script, errs := compileProgram(t, "print 1 / 0;\nprint -1 / 0;\nprint 0 / 0;")
+	require.Empty(t, errs)
+
+	var out bytes.Buffer
+	vm := gx.NewVM()
+	vm.SetOutput(&out)
+	vm.SetDivisionByZero(gx.DivisionByZeroIEEE)
+	require.NoError(t, vm.Interpret(script))
+	assert.Equal(t, "Infinity\n-Infinity\nNaN\n", out.String())
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	gx "golox/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostic_RendersSnippetAndCaret(t *testing.T) {
+	source := "var a = 1;\nprint a +;\n"
+	printer := gx.DiagnosticPrinter{File: "test.gx", Source: []byte(source)}
+	assert.Equal(t,
+		"test.gx:2:10: error: Expected expression.\n"+
+			"    2 | print a +;\n"+
+			"      |          ^\n",
+		printer.RenderErrors(parseErrorValues(source)))
+}
+
+func TestDiagnostic_UnderlinesWholeSpan(t *testing.T) {
+	printer := gx.DiagnosticPrinter{File: "test.gx", Source: []byte("\tprint \"é unterminated\n")}
+	assert.Equal(t,
+		"test.gx:1:8: error: Unterminated string.\n"+
+			"    1 | \tprint \"é unterminated\n"+
+			"      | \t      ^~~~~~~~~~~~~~~\n",
+		printer.Render(gx.Diagnostic{
+			Severity: gx.SeverityError,
+			Message:  "Unterminated string.",
+			Span: gx.Span{
+				Start: gx.Position{Line: 1, Column: 8, Offset: 7},
+				End:   gx.Position{Line: 1, Column: 23, Offset: 23},
+			},
+		}))
+}
+
+// Errors of other kinds have no span, so there is no snippet to show even
+// when the source is known.
+func TestDiagnostic_WithoutSpan(t *testing.T) {
+	printer := gx.DiagnosticPrinter{File: "x.gx", Source: []byte("print 1;\n")}
+	assert.Equal(t, "x.gx: error: boom\n", printer.Render(gx.NewDiagnostic(errors.New("boom"))))
+	assert.Equal(t, "x.gx: error: boom\n", printer.RenderErrors([]error{errors.New("boom")}))
+}
+
+func TestDiagnostic_SuggestsCloseNames(t *testing.T) {
+	source := "var count = 1;\nfun bump() {\n  count = conut + 1;\n}\nbump();\n"
+	_, err := runProgram(t, source)
+	require.Error(t, err)
+
+	printer := gx.DiagnosticPrinter{File: "test.gx", Source: []byte(source)}
+	assert.Equal(t,
+		"test.gx:3:11: error: Undefined variable 'conut'.\n"+
+			"    3 |   count = conut + 1;\n"+
+			"      |           ^~~~~\n"+
+			"      = help: did you mean 'count'?\n",
+		printer.RenderErrors([]error{err}))
+}
+
+func TestDiagnostic_SuggestsProperties(t *testing.T) {
+	_, err := runProgram(t, "class A {\n  method() {}\n}\nvar a = A();\na.field = 1;\na.methdo();\n")
+	var runtimeErr *gx.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	assert.Equal(t, "method", runtimeErr.Suggestion)
+
+	_, err = runProgram(t, "var a = 1;\nprint zzz;\n")
+	require.ErrorAs(t, err, &runtimeErr)
+	assert.Empty(t, runtimeErr.Suggestion)
+}
+
+func TestDiagnostic_Notes(t *testing.T) {
+	source := "{\n  var x = 1;\n  var x = 2;\n  print x;\n}\n"
+	diagnostics := resolveErrors(t, source)
+	require.Len(t, diagnostics, 1)
+
+	scanner := gx.NewScanner([]byte(source))
+	tokens, _ := scanner.ScanTokens()
+	parser := gx.NewParser(tokens)
+	statements, _ := parser.Parse()
+	resolver := gx.NewResolver(gx.NewInterpreter())
+	printer := gx.DiagnosticPrinter{File: "test.gx", Source: []byte(source)}
+	assert.Equal(t,
+		"test.gx:3:7: error: Already a variable with this name in this scope.\n"+
+			"    3 |   var x = 2;\n"+
+			"      |       ^\n"+
+			"      = note: 'x' was first declared at 2:7.\n",
+		printer.RenderErrors(resolver.Resolve(statements)))
+}
+
+func TestDiagnostic_Color(t *testing.T) {
+	source := "print a +;\n"
+	printer := gx.DiagnosticPrinter{File: "test.gx", Source: []byte(source), Color: true}
+	rendered := printer.RenderErrors(parseErrorValues(source))
+	assert.Contains(t, rendered, "\x1b[1m\x1b[31merror:\x1b[0m")
+	assert.Contains(t, rendered, "\x1b[1m\x1b[31m^\x1b[0m")
+}
+
+func parseErrorValues(source string) []error {
+	scanner := gx.NewScanner([]byte(source))
+	tokens, scanErrors := scanner.ScanTokens()
+	parser := gx.NewParser(tokens)
+	_, parseErrors := parser.Parse()
+	return append(scanErrors, parseErrors...)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	gx "golox/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanner_CollectsAllErrors(t *testing.T) {
+	scanner := gx.NewScanner([]byte("var a = 1 @ 2;\nprint #;\n"))
+	tokens, errs := scanner.ScanTokens()
+
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "1:11: Unexpected character '@'.", errs[0].Error())
+	assert.Equal(t, "2:7: Unexpected character '#'.", errs[1].Error())
+
+	var scanErr *gx.ScanError
+	assert.ErrorAs(t, errs[0], &scanErr)
+	assert.Equal(t, "@", scanErr.Token.Lexeme)
+	assert.Equal(t, gx.EOF, tokens[len(tokens)-1].TokenType)
+}
+
+func TestScanner_UnterminatedString(t *testing.T) {
+	scanner := gx.NewScanner([]byte("print \"oops;\n"))
+	_, errs := scanner.ScanTokens()
+
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "1:7: Unterminated string.", errs[0].Error())
+}
+
+func TestParser_ReturnsParseError(t *testing.T) {
+	scanner := gx.NewScanner([]byte("var a = 1;\nprint a\n"))
+	tokens, _ := scanner.ScanTokens()
+	parser := gx.NewParser(tokens)
+	_, errs := parser.Parse()
+
+	assert.Len(t, errs, 1)
+	var parseErr *gx.ParseError
+	assert.ErrorAs(t, errs[0], &parseErr)
+	assert.Equal(t, gx.EOF, parseErr.Token.TokenType)
+	assert.Equal(t, "3:1: at end: Expected semicolon `;' after expression.", errs[0].Error())
+}
+
+func TestParser_InvalidAssignmentTarget(t *testing.T) {
+	scanner := gx.NewScanner([]byte("var a = 2;\nvar b = 3;\na + b = 5;\n"))
+	tokens, _ := scanner.ScanTokens()
+	parser := gx.NewParser(tokens)
+	_, errs := parser.Parse()
+
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "3:7: at '=': Invalid assignment target.", errs[0].Error())
+}
+
+func TestInterpreter_ReturnsRuntimeError(t *testing.T) {
+	scanner := gx.NewScanner([]byte("var a = 1;\nprint b;\n"))
+	tokens, _ := scanner.ScanTokens()
+	parser := gx.NewParser(tokens)
+	statements, _ := parser.Parse()
+
+	err := gx.NewInterpreter().Interpret(statements)
+	var runtimeErr *gx.RuntimeError
+	assert.ErrorAs(t, err, &runtimeErr)
+	assert.Equal(t, "2:7: Undefined variable 'b'.", err.Error())
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	gx "golox/internal"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// formatSources are the files every formatter guarantee is checked against:
+// the golden inputs and the example programs that parse.
+func formatSources(t *testing.T) map[string]string {
+	files, err := filepath.Glob("testdata/format/*.gx")
+	require.NoError(t, err)
+	examples, err := filepath.Glob("../example/*.gx")
+	require.NoError(t, err)
+
+	sources := make(map[string]string)
+	for _, file := range append(files, examples...) {
+		source, err := os.ReadFile(file)
+		require.NoError(t, err)
+		if _, errs := gx.Format(source); len(errs) == 0 {
+			sources[file] = string(source)
+		}
+	}
+	require.NotEmpty(t, sources)
+	return sources
+}
+
+func format(t *testing.T, source string) string {
+	t.Helper()
+	formatted, errs := gx.Format([]byte(source))
+	require.Empty(t, errs)
+	return string(formatted)
+}
+
+// Each testdata/format/<name>.gx file is paired with <name>.expected, its
+// canonical formatting.
+func TestFormat_Golden(t *testing.T) {
+	files, err := filepath.Glob("testdata/format/*.gx")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			require.NoError(t, err)
+			expected, err := os.ReadFile(strings.TrimSuffix(file, ".gx") + ".expected")
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), format(t, string(source)))
+		})
+	}
+}
+
+func TestFormat_Idempotent(t *testing.T) {
+	for file, source := range formatSources(t) {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			once := format(t, source)
+			assert.Equal(t, once, format(t, once))
+		})
+	}
+}
+
+func TestFormat_PreservesAst(t *testing.T) {
+	for file, source := range formatSources(t) {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			before := gx.AstPrinter{}.Print(parse(t, source))
+			after := gx.AstPrinter{}.Print(parse(t, format(t, source)))
+			assert.Equal(t, before, after)
+		})
+	}
+}
+
+func TestFormat_PreservesComments(t *testing.T) {
+	for file, source := range formatSources(t) {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			assert.Equal(t, comments(source), comments(format(t, source)))
+		})
+	}
+}
+
+func TestFormat_NestedLoops(t *testing.T) {
+	assert.Equal(t,
+		"for (var i = 0; i < 2; i = i + 1)\n"+
+			"  for (var j = 0; j < 2; j = j + 1)\n"+
+			"    print i * j;\n"+
+			"if (true)\n"+
+			"  for (; false;)\n"+
+			"    print 1;\n"+
+			"else\n"+
+			"  print 2;\n",
+		format(t, "for(var i=0;i<2;i=i+1) for (var j=0;j<2;j=j+1) print i*j;\nif (true) for(;false;) print 1; else print 2;\n"))
+}
+
+func TestFormat_KeepsShebang(t *testing.T) {
+	assert.Equal(t, "#!/usr/bin/env golox\nprint 1;\n", format(t, "#!/usr/bin/env golox\nprint   1;\n"))
+}
+
+func TestFormat_RejectsSyntaxErrors(t *testing.T) {
+	formatted, errs := gx.Format([]byte("var a = ;\n"))
+	assert.Nil(t, formatted)
+	assert.Len(t, errs, 1)
+}
+
+func TestScanner_KeepsComments(t *testing.T) {
+	scanner := gx.NewScanner([]byte("// one\nvar a = 1; // two\r\nprint a / 2;"))
+	tokens, errs := scanner.ScanTokens()
+	require.Empty(t, errs)
+	assert.Len(t, tokens, 11)
+
+	require.Len(t, scanner.Comments, 2)
+	assert.Equal(t, gx.COMMENT, scanner.Comments[0].TokenType)
+	assert.Equal(t, "// one", scanner.Comments[0].Lexeme)
+	assert.Equal(t, "// two", scanner.Comments[1].Lexeme)
+	assert.Equal(t, 2, scanner.Comments[1].Line)
+	assert.Equal(t, 12, scanner.Comments[1].Column)
+}
+
+func comments(source string) []string {
+	scanner := gx.NewScanner([]byte(source))
+	scanner.ScanTokens()
+	var texts []string
+	for _, comment := range scanner.Comments {
+		texts = append(texts, comment.Lexeme)
+	}
+	return texts
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunction_ReturnValue(t *testing.T) {
+	out, err := runProgram(t, `
+fun answer() {
+  return 42;
+}
+print answer();
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "42\n", out)
+}
+
+func TestFunction_ReturnUnwindsBlocksAndLoops(t *testing.T) {
+	out, err := runProgram(t, `
+var i = 0;
+fun firstOverThree() {
+  while (true) {
+    {
+      i = i + 1;
+      if (i > 3) {
+        return i;
+      }
+    }
+  }
+  print "unreachable";
+}
+print firstOverThree();
+print i;
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "4\n4\n", out)
+}
+
+func TestFunction_BareReturnYieldsNil(t *testing.T) {
+	out, err := runProgram(t, `
+fun procedure() {
+  print "before";
+  return;
+  print "after";
+}
+var result = procedure();
+print result == nil;
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "before\ntrue\n", out)
+}
+
+func TestFunction_ReturnAtTopLevelIsAnError(t *testing.T) {
+	assert.Equal(t,
+		[]string{"2:1: at 'return': Can't return from top-level code."},
+		parseErrors("print 1;\nreturn 2;\n"))
+}
+
+func TestFunction_ParametersDoNotLeakIntoGlobals(t *testing.T) {
+	_, err := runProgram(t, `
+fun procedure() {
+  var local = "inside";
+  print local;
+}
+procedure();
+print local;
+`)
+	assert.EqualError(t, err, "7:7: Undefined variable 'local'.")
+}
+
+func TestFunction_NestedFunctions(t *testing.T) {
+	out, err := runProgram(t, `
+fun outer() {
+  var x = "outer";
+  fun inner() {
+    print x;
+  }
+  inner();
+}
+outer();
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "outer\n", out)
+}
+
+func TestFunction_RecursionKeepsLocalsPerCall(t *testing.T) {
+	out, err := runProgram(t, `
+var n = 3;
+fun countdown() {
+  var mine = n;
+  n = n - 1;
+  if (n > 0) countdown();
+  print mine;
+}
+countdown();
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n2\n3\n", out)
+}
+
+func TestFunction_Counter(t *testing.T) {
+	out, err := runProgram(t, `
+fun makeCounter() {
+  var i = 0;
+  fun count() {
+    i = i + 1;
+    print i;
+  }
+  return count;
+}
+
+var counter = makeCounter();
+counter();
+counter();
+var other = makeCounter();
+other();
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n2\n1\n", out)
+}
+
+func TestFunction_ClosuresCaptureLoopVariables(t *testing.T) {
+	out, err := runProgram(t, `
+var first;
+var second;
+var last;
+for (var i = 1; i < 3; i = i + 1) {
+  var j = i;
+  fun showJ() {
+    print j;
+  }
+  fun showI() {
+    print i;
+  }
+  if (j == 1) first = showJ;
+  else second = showJ;
+  last = showI;
+}
+first();
+second();
+last();
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n2\n3\n", out)
+}
+
+func TestFunction_ArgumentsAreEvaluated(t *testing.T) {
+	out, err := runProgram(t, `
+fun add(a, b, c) {
+  return a + b + c;
+}
+var x = 1;
+print add(x, x + 1, add(1, 1, 1));
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "6\n", out)
+}
+
+func TestFunction_ArgumentsEvaluateLeftToRight(t *testing.T) {
+	out, err := runProgram(t, `
+fun trace(label) {
+  print label;
+  return label;
+}
+fun pair(a, b) {
+  return a + b;
+}
+print pair(trace("left"), trace("right"));
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "left\nright\nleftright\n", out)
+}
+
+func TestFunction_Fibonacci(t *testing.T) {
+	out, err := runProgram(t, `
+fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+print fib(15);
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "610\n", out)
+}
+
+func TestFunction_ArityMismatch(t *testing.T) {
+	_, err := runProgram(t, `
+fun add(a, b) {
+  return a + b;
+}
+print add(1);
+`)
+	assert.EqualError(t, err, "5:12: <fn add> expected 2 arguments but got 1.")
+}
+
+func TestFunction_ArityMismatchSingular(t *testing.T) {
+	for name, run := range backends {
+		_, err := run(t, "fun twice(a) { return a * 2; }\nprint twice();")
+		assert.EqualError(t, err, "2:13: <fn twice> expected 1 argument but got 0.", name)
+	}
+}
+
+func TestFunction_CallingNonFunction(t *testing.T) {
+	_, err := runProgram(t, "var notFn = 1;\nnotFn();\n")
+	assert.EqualError(t, err, "2:7: Can only call functions and classes.")
+}
+
+func TestFunction_NativeClock(t *testing.T) {
+	out, err := runProgram(t, "print clock() > 0;\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "true\n", out)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	gx "golox/internal"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runProgram scans, parses, resolves and interprets source, returning
+// everything it printed along with the runtime error, if any. Static errors
+// fail the test.
+func runProgram(t *testing.T, source string) (string, error) {
+	t.Helper()
+	scanner := gx.NewScanner([]byte(source))
+	tokens, scanErrors := scanner.ScanTokens()
+	require.Empty(t, scanErrors)
+	parser := gx.NewParser(tokens)
+	statements, parseErrors := parser.Parse()
+	require.Empty(t, parseErrors)
+
+	var out bytes.Buffer
+	interpreter := gx.NewInterpreter()
+	interpreter.SetOutput(&out)
+	resolver := gx.NewResolver(interpreter)
+	require.Empty(t, resolver.Resolve(statements))
+	err := interpreter.Interpret(statements)
+	return out.String(), err
+}
+
+// parseErrors returns the diagnostics reported while scanning and parsing
+// source.
+func parseErrors(source string) []string {
+	scanner := gx.NewScanner([]byte(source))
+	tokens, scanErrors := scanner.ScanTokens()
+	parser := gx.NewParser(tokens)
+	_, parseErrors := parser.Parse()
+
+	diagnostics := []string{}
+	for _, err := range append(scanErrors, parseErrors...) {
+		diagnostics = append(diagnostics, err.Error())
+	}
+	return diagnostics
+}
+
+// resolveErrors returns the diagnostics reported by the Resolver for source,
+// which must scan and parse cleanly.
+func resolveErrors(t *testing.T, source string) []string {
+	t.Helper()
+	scanner := gx.NewScanner([]byte(source))
+	tokens, scanErrors := scanner.ScanTokens()
+	require.Empty(t, scanErrors)
+	parser := gx.NewParser(tokens)
+	statements, parseErrors := parser.Parse()
+	require.Empty(t, parseErrors)
+
+	resolver := gx.NewResolver(gx.NewInterpreter())
+	diagnostics := []string{}
+	for _, err := range append(resolver.Resolve(statements), resolver.Warnings...) {
+		diagnostics = append(diagnostics, err.Error())
+	}
+	return diagnostics
+}
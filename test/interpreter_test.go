@@ -2,18 +2,27 @@ package main
 
 import (
 	gx "golox/internal"
-	"github.com/stretchr/testify/assert"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+func evaluate(t *testing.T, interpreter *gx.Interpreter, expr gx.Expr) gx.Value {
+	t.Helper()
+	result, err := interpreter.Evaluate(expr)
+	assert.NoError(t, err)
+	return result
+}
+
 func TestInterpreter_Interpret_Literal(t *testing.T) {
 	// Test literal value (e.g., number 5)
 	interpreter := &gx.Interpreter{}
 	literalExpr := &gx.Literal{Value: 5.0}
-	result := interpreter.Interpret(literalExpr)
+	result := evaluate(t, interpreter, literalExpr)
 
 	// Assert the result is the value of the literal
-	assert.Equal(t, 5.0, result)
+	assert.Equal(t, gx.FloatValue(5.0), result)
 }
 
 func TestInterpreter_Interpret_Binary(t *testing.T) {
@@ -25,34 +34,49 @@ func TestInterpreter_Interpret_Binary(t *testing.T) {
 	operator := gx.Token{TokenType: gx.PLUS}
 	binaryExpr := &gx.Binary{Left: left, Right: right, Operator: operator}
 	interpreter := &gx.Interpreter{}
-	result := interpreter.Interpret(binaryExpr)
+	result := evaluate(t, interpreter, binaryExpr)
 
 	// Assert the result is the sum of the two values
-	assert.Equal(t, 8.0, result)
+	assert.Equal(t, gx.FloatValue(8.0), result)
 
 	// 5 - 3
 	operator = gx.Token{TokenType: gx.MINUS}
 	binaryExpr = &gx.Binary{Left: left, Right: right, Operator: operator}
-	result = interpreter.Interpret(binaryExpr)
-	assert.Equal(t, 2.0, result)
+	result = evaluate(t, interpreter, binaryExpr)
+	assert.Equal(t, gx.FloatValue(2.0), result)
 
 	// 5 * 3
 	operator = gx.Token{TokenType: gx.STAR}
 	binaryExpr = &gx.Binary{Left: left, Right: right, Operator: operator}
-	result = interpreter.Interpret(binaryExpr)
-	assert.Equal(t, 15.0, result)
+	result = evaluate(t, interpreter, binaryExpr)
+	assert.Equal(t, gx.FloatValue(15.0), result)
 
 	// 5 / 3
 	operator = gx.Token{TokenType: gx.SLASH}
 	binaryExpr = &gx.Binary{Left: left, Right: right, Operator: operator}
-	result = interpreter.Interpret(binaryExpr)
-	assert.Equal(t, 1.6666666666666667, result)
+	result = evaluate(t, interpreter, binaryExpr)
+	assert.Equal(t, gx.FloatValue(1.6666666666666667), result)
 
 	// 5 == 3
 	operator = gx.Token{TokenType: gx.EQUAL_EQUAL}
 	binaryExpr = &gx.Binary{Left: left, Right: right, Operator: operator}
-	result = interpreter.Interpret(binaryExpr)
-	assert.Equal(t, false, result)
+	result = evaluate(t, interpreter, binaryExpr)
+	assert.Equal(t, gx.BoolValue(false), result)
+}
+
+func TestInterpreter_Interpret_IntDivision(t *testing.T) {
+	interpreter := &gx.Interpreter{}
+	divide := func(op gx.TokenType, left, right int64) gx.Value {
+		operator := gx.Token{TokenType: op}
+		return evaluate(t, interpreter, &gx.Binary{Left: &gx.Literal{Value: left}, Right: &gx.Literal{Value: right}, Operator: operator})
+	}
+
+	// `/' gives a float even when two ints divide exactly.
+	assert.Equal(t, gx.FloatValue(2), divide(gx.SLASH, 4, 2))
+	assert.Equal(t, "2.0", divide(gx.SLASH, 4, 2).String())
+	assert.Equal(t, gx.IntValue(2), divide(gx.TILDE_SLASH, 4, 2))
+	assert.Equal(t, gx.IntValue(-4), divide(gx.TILDE_SLASH, -7, 2))
+	assert.Equal(t, gx.IntValue(2), divide(gx.PERCENT, -7, 3))
 }
 
 func TestInterpreter_Interpret_Unary(t *testing.T) {
@@ -63,27 +87,27 @@ func TestInterpreter_Interpret_Unary(t *testing.T) {
 	operator := gx.Token{TokenType: gx.MINUS}
 	unaryExpr := &gx.Unary{Operator: operator, Right: left}
 	interpreter := &gx.Interpreter{}
-	result := interpreter.Interpret(unaryExpr)
+	result := evaluate(t, interpreter, unaryExpr)
 
 	// Assert the result is the negation of the literal value
-	assert.Equal(t, -5.0, result)
+	assert.Equal(t, gx.FloatValue(-5.0), result)
 
 	// !true
 	right := &gx.Literal{Value: true}
 	operator = gx.Token{TokenType: gx.BANG}
 	unaryExpr = &gx.Unary{Operator: operator, Right: right}
-	result = interpreter.Interpret(unaryExpr)
+	result = evaluate(t, interpreter, unaryExpr)
 
 	// Assert the result is the negation of true (i.e., false)
-	assert.Equal(t, false, result)
+	assert.Equal(t, gx.BoolValue(false), result)
 
 	// !false
 	right = &gx.Literal{Value: false}
 	unaryExpr = &gx.Unary{Operator: operator, Right: right}
-	result = interpreter.Interpret(unaryExpr)
+	result = evaluate(t, interpreter, unaryExpr)
 
 	// Assert the result is the negation of false (i.e., true)
-	assert.Equal(t, true, result)
+	assert.Equal(t, gx.BoolValue(true), result)
 }
 
 func TestInterpreter_Interpret_Comparison(t *testing.T) {
@@ -95,18 +119,18 @@ func TestInterpreter_Interpret_Comparison(t *testing.T) {
 	operator := gx.Token{TokenType: gx.GREATER}
 	binaryExpr := &gx.Binary{Left: left, Right: right, Operator: operator}
 	interpreter := &gx.Interpreter{}
-	result := interpreter.Interpret(binaryExpr)
+	result := evaluate(t, interpreter, binaryExpr)
 
 	// Assert the result is true
-	assert.Equal(t, true, result)
+	assert.Equal(t, gx.BoolValue(true), result)
 
 	// 5 <= 3
 	operator = gx.Token{TokenType: gx.LESS_EQUAL}
 	binaryExpr = &gx.Binary{Left: left, Right: right, Operator: operator}
-	result = interpreter.Interpret(binaryExpr)
+	result = evaluate(t, interpreter, binaryExpr)
 
 	// Assert the result is false
-	assert.Equal(t, false, result)
+	assert.Equal(t, gx.BoolValue(false), result)
 }
 
 func TestInterpreter_Interpret_Equality(t *testing.T) {
@@ -118,18 +142,18 @@ func TestInterpreter_Interpret_Equality(t *testing.T) {
 	operator := gx.Token{TokenType: gx.EQUAL_EQUAL}
 	binaryExpr := &gx.Binary{Left: left, Right: right, Operator: operator}
 	interpreter := &gx.Interpreter{}
-	result := interpreter.Interpret(binaryExpr)
+	result := evaluate(t, interpreter, binaryExpr)
 
 	// Assert the result is true
-	assert.Equal(t, true, result)
+	assert.Equal(t, gx.BoolValue(true), result)
 
 	// 5 != 3
 	operator = gx.Token{TokenType: gx.BANG_EQUAL}
 	binaryExpr = &gx.Binary{Left: left, Right: right, Operator: operator}
-	result = interpreter.Interpret(binaryExpr)
+	result = evaluate(t, interpreter, binaryExpr)
 
 	// Assert the result is false
-	assert.Equal(t, false, result)
+	assert.Equal(t, gx.BoolValue(false), result)
 }
 
 func TestInterpreter_Interpret_Grouping(t *testing.T) {
@@ -142,83 +166,111 @@ func TestInterpreter_Interpret_Grouping(t *testing.T) {
 	binaryExpr := &gx.Binary{Left: left, Right: right, Operator: operator}
 	groupingExpr := &gx.Grouping{Inside: binaryExpr}
 	interpreter := &gx.Interpreter{}
-	result := interpreter.Interpret(groupingExpr)
+	result := evaluate(t, interpreter, groupingExpr)
 
 	// Assert the result is the sum of the two values
-	assert.Equal(t, 8.0, result)
+	assert.Equal(t, gx.FloatValue(8.0), result)
 }
 
-
 func TestInterpreter_Interpret_Literal_EdgeCases(t *testing.T) {
 	interpreter := &gx.Interpreter{}
 
 	// Test 0
 	literalZero := &gx.Literal{Value: 0.0}
-	result := interpreter.Interpret(literalZero)
-	assert.Equal(t, 0.0, result)
+	result := evaluate(t, interpreter, literalZero)
+	assert.Equal(t, gx.FloatValue(0.0), result)
 
 	// Test negative numbers
 	literalNegative := &gx.Literal{Value: -42.5}
-	result = interpreter.Interpret(literalNegative)
-	assert.Equal(t, -42.5, result)
+	result = evaluate(t, interpreter, literalNegative)
+	assert.Equal(t, gx.FloatValue(-42.5), result)
 
 	// Test string literals
 	literalString := &gx.Literal{Value: "Hello, Lox!"}
-	result = interpreter.Interpret(literalString)
-	assert.Equal(t, "Hello, Lox!", result)
+	result = evaluate(t, interpreter, literalString)
+	assert.Equal(t, gx.StringValue("Hello, Lox!"), result)
 
 	// Test boolean literals
 	literalTrue := &gx.Literal{Value: true}
-	result = interpreter.Interpret(literalTrue)
-	assert.Equal(t, true, result)
+	result = evaluate(t, interpreter, literalTrue)
+	assert.Equal(t, gx.BoolValue(true), result)
 
 	literalFalse := &gx.Literal{Value: false}
-	result = interpreter.Interpret(literalFalse)
-	assert.Equal(t, false, result)
+	result = evaluate(t, interpreter, literalFalse)
+	assert.Equal(t, gx.BoolValue(false), result)
 }
 
-
 func TestInterpreter_Interpret_DivisionByZero(t *testing.T) {
 	interpreter := &gx.Interpreter{}
+	interpreter.SetDivisionByZero(gx.DivisionByZeroError)
 
 	left := &gx.Literal{Value: 5.0}
 	right := &gx.Literal{Value: 0.0}
 	operator := gx.Token{TokenType: gx.SLASH}
 	binaryExpr := &gx.Binary{Left: left, Right: right, Operator: operator}
 
-	// Expect an error or a specific behavior (e.g., return nil or panic)
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("Expected division by zero to cause an error or panic")
-		}
-	}()
-	interpreter.Interpret(binaryExpr)
+	_, err := interpreter.Evaluate(binaryExpr)
+	var runtimeErr *gx.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	assert.Equal(t, "Division by zero.", runtimeErr.Message)
+}
+
+func TestInterpreter_Interpret_DivisionByZeroIEEE(t *testing.T) {
+	interpreter := &gx.Interpreter{}
+	interpreter.SetDivisionByZero(gx.DivisionByZeroIEEE)
+
+	divide := func(left, right float64) gx.Value {
+		operator := gx.Token{TokenType: gx.SLASH}
+		return evaluate(t, interpreter, &gx.Binary{Left: &gx.Literal{Value: left}, Right: &gx.Literal{Value: right}, Operator: operator})
+	}
+	assert.Equal(t, "Infinity", divide(5, 0).String())
+	assert.Equal(t, "-Infinity", divide(-5, 0).String())
+	assert.Equal(t, "NaN", divide(0, 0).String())
+}
+
+func TestInterpreter_Interpret_OperandTypeErrors(t *testing.T) {
+	tests := []struct {
+		expr    gx.Expr
+		message string
+	}{
+		{&gx.Binary{Left: &gx.Literal{Value: 2.0}, Operator: gx.Token{TokenType: gx.PLUS, Line: 1, Column: 3}, Right: &gx.Literal{Value: "x"}},
+			"1:3: Operands must be two numbers or two strings."},
+		{&gx.Binary{Left: &gx.Literal{Value: true}, Operator: gx.Token{TokenType: gx.STAR, Line: 1, Column: 6}, Right: &gx.Literal{Value: 2.0}},
+			"1:6: Operands must be numbers."},
+		{&gx.Binary{Left: &gx.Literal{Value: "a"}, Operator: gx.Token{TokenType: gx.LESS, Line: 2, Column: 5}, Right: &gx.Literal{Value: "b"}},
+			"2:5: Operands must be numbers."},
+		{&gx.Unary{Operator: gx.Token{TokenType: gx.MINUS, Line: 1, Column: 1}, Right: &gx.Literal{Value: "a"}},
+			"1:1: Operand must be a number."},
+	}
+	for _, test := range tests {
+		_, err := (&gx.Interpreter{}).Evaluate(test.expr)
+		assert.EqualError(t, err, test.message)
+	}
 }
 
 func TestInterpreter_Interpret_StringConcatenation(t *testing.T) {
-  interpreter := &gx.Interpreter{}
+	interpreter := &gx.Interpreter{}
 
-  left := &gx.Literal{Value: "Hello"}
-  right := &gx.Literal{Value: " World"}
-  operator := gx.Token{TokenType: gx.PLUS}
-  binaryExpr := &gx.Binary{Left: left, Right: right, Operator: operator}
+	left := &gx.Literal{Value: "Hello"}
+	right := &gx.Literal{Value: " World"}
+	operator := gx.Token{TokenType: gx.PLUS}
+	binaryExpr := &gx.Binary{Left: left, Right: right, Operator: operator}
 
-  result := interpreter.Interpret(binaryExpr)
-  assert.Equal(t, "Hello World", result)
+	result := evaluate(t, interpreter, binaryExpr)
+	assert.Equal(t, gx.StringValue("Hello World"), result)
 }
 
 func TestInterpreter_Interpret_3StringConcatenation(t *testing.T) {
-  interpreter := &gx.Interpreter{}
+	interpreter := &gx.Interpreter{}
 
-  left := &gx.Literal{Value: "Hello"}
-  mid := &gx.Literal{Value: " Good"}
-  right := &gx.Literal{Value: " World"}
-  operator := gx.Token{TokenType: gx.PLUS}
+	left := &gx.Literal{Value: "Hello"}
+	mid := &gx.Literal{Value: " Good"}
+	right := &gx.Literal{Value: " World"}
+	operator := gx.Token{TokenType: gx.PLUS}
 
-  leftMid := &gx.Binary{Left: left, Right: mid, Operator: operator}
-  binaryExpr := &gx.Binary{Left: leftMid, Right: right, Operator: operator}
+	leftMid := &gx.Binary{Left: left, Right: mid, Operator: operator}
+	binaryExpr := &gx.Binary{Left: leftMid, Right: right, Operator: operator}
 
-  result := interpreter.Interpret(binaryExpr)
-  assert.Equal(t, "Hello Good World", result)
+	result := evaluate(t, interpreter, binaryExpr)
+	assert.Equal(t, gx.StringValue("Hello Good World"), result)
 }
-
@@ -0,0 +1,132 @@
+package main
+
+import (
+	gx "golox/internal"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lint(t *testing.T, source string, config gx.LintConfig) []string {
+	t.Helper()
+	var warnings []string
+	for _, err := range gx.Lint([]byte(source), config) {
+		require.IsType(t, &gx.LintWarning{}, err)
+		warnings = append(warnings, err.Error())
+	}
+	return warnings
+}
+
+// only enables a single rule, so each test sees just the warnings it is about.
+func only(rule string) gx.LintConfig {
+	config := gx.LintConfig{Rules: map[string]bool{}}
+	for _, r := range gx.LintRules {
+		config.Rules[r.Name] = r.Name == rule
+	}
+	return config
+}
+
+func TestLint_UnusedVariable(t *testing.T) {
+	assert.Equal(t, []string{
+		"1:5: Variable 'a' is never read. [unused-variable]",
+		"4:7: Variable 'c' is never read. [unused-variable]",
+	}, lint(t, "var a = 1;\nvar b = 2;\n{\n  var c;\n  c = b;\n}\n", only("unused-variable")))
+}
+
+func TestLint_UnreachableCode(t *testing.T) {
+	assert.Equal(t, []string{
+		"3:3: Unreachable code after 'return'. [unreachable-code]",
+	}, lint(t, "fun f() {\n  return 1;\n  print 2;\n  print 3;\n}\nfun g() {\n  for (;;) return;\n}\n", only("unreachable-code")))
+}
+
+func TestLint_AssignInCondition(t *testing.T) {
+	assert.Equal(t, []string{
+		"2:5: Assignment used as a condition; did you mean '=='? [assign-in-condition]",
+		"3:8: Assignment used as a condition; did you mean '=='? [assign-in-condition]",
+		"4:8: Assignment used as a condition; did you mean '=='? [assign-in-condition]",
+	}, lint(t, "var a;\nif (a = 1) print a;\nwhile (a.b = 2) print a;\nfor (; a = nil;) print a;\nif (a == 1) print a;\nwhile ((a = nil)) print a;\n", only("assign-in-condition")))
+}
+
+func TestLint_LiteralTypeComparison(t *testing.T) {
+	source, err := os.ReadFile("../example/equality.gx")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"7:7: Comparing a number with a string is always false. [literal-type-comparison]",
+	}, lint(t, string(source), only("literal-type-comparison")))
+
+	assert.Equal(t, []string{
+		"1:7: Comparing a nil with a boolean is always true. [literal-type-comparison]",
+	}, lint(t, "print nil != false;\nprint 1 == 2;\n", only("literal-type-comparison")))
+}
+
+func TestLint_Shadowing(t *testing.T) {
+	assert.Equal(t, []string{
+		"2:7: 'a' shadows the variable declared at 1:5. [shadowing]",
+		"3:9: 'a' shadows the parameter declared at 2:7. [shadowing]",
+		"5:7: 'a' shadows the parameter declared at 2:7. [shadowing]",
+	}, lint(t, "var a = 1;\nfun f(a) {\n  { var a = 2; print a; }\n  {\n  var a = 3;\n  print a;\n  }\n}\n", only("shadowing")))
+}
+
+func TestLint_EmptyBlock(t *testing.T) {
+	assert.Equal(t, []string{
+		"1:13: Empty block. [empty-block]",
+		"2:1: Empty block. [empty-block]",
+	}, lint(t, "while (nil) {}\n{\n}\n{\n  // on purpose\n}\nfor (;;) print 1;\n", only("empty-block")))
+}
+
+func TestLint_IgnoreComments(t *testing.T) {
+	source := "var a; // golox:ignore unused-variable\n" +
+		"// golox:ignore unused-variable, empty-block\n" +
+		"var b; {}\n" +
+		"// golox:ignore\n" +
+		"var c; {}\n" +
+		"// golox:ignore empty-block\n" +
+		"var d;\n" +
+		"// golox:ignored\n" +
+		"var e;\n"
+	assert.Equal(t, []string{
+		"7:5: Variable 'd' is never read. [unused-variable]",
+		"9:5: Variable 'e' is never read. [unused-variable]",
+	}, lint(t, source, gx.LintConfig{}))
+}
+
+func TestLint_Config(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lint.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": {"unused-variable": false}}`), 0o644))
+
+	config, err := gx.LoadLintConfig(path)
+	require.NoError(t, err)
+	assert.False(t, config.Enabled("unused-variable"))
+	assert.True(t, config.Enabled("empty-block"))
+	assert.Equal(t, []string{"1:8: Empty block. [empty-block]"}, lint(t, "var a; {}\n", config))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": {"unused-variables": false}}`), 0o644))
+	_, err = gx.LoadLintConfig(path)
+	assert.ErrorContains(t, err, `unknown lint rule "unused-variables"`)
+}
+
+func TestLint_SyntaxErrorsInsteadOfWarnings(t *testing.T) {
+	errs := gx.Lint([]byte("var a = ;\n"), gx.LintConfig{})
+	require.Len(t, errs, 1)
+	assert.IsType(t, &gx.ParseError{}, errs[0])
+}
+
+func TestLint_Diagnostics(t *testing.T) {
+	source := []byte("print 5 == \"5\";\n")
+	errs := gx.Lint(source, gx.LintConfig{})
+	printer := gx.DiagnosticPrinter{File: "eq.gx", Source: source}
+	assert.Equal(t,
+		"eq.gx:1:7: warning: Comparing a number with a string is always false.\n"+
+			"    1 | print 5 == \"5\";\n"+
+			"      |       ^~~~~~~~\n",
+		printer.RenderErrors(errs))
+
+	data, err := printer.JSON(errs)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"severity": "warning"`)
+	assert.Contains(t, string(data), `"code": "literal-type-comparison"`)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	gx "golox/internal"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const lspURI = "file:///work/script.gx"
+
+// lspScript collects the messages a client would send, runs a server over
+// them and splits its output into responses (by id) and notifications.
+type lspScript struct {
+	input  bytes.Buffer
+	nextID int
+}
+
+type lspReply struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newLspScript(source string) *lspScript {
+	script := &lspScript{}
+	script.request("initialize", map[string]any{"capabilities": map[string]any{}})
+	script.notify("initialized", map[string]any{})
+	script.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": lspURI, "languageId": "lox", "version": 1, "text": source},
+	})
+	return script
+}
+
+func (s *lspScript) write(message map[string]any) {
+	message["jsonrpc"] = "2.0"
+	body, _ := json.Marshal(message)
+	fmt.Fprintf(&s.input, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *lspScript) request(method string, params any) int {
+	s.nextID++
+	s.write(map[string]any{"id": s.nextID, "method": method, "params": params})
+	return s.nextID
+}
+
+func (s *lspScript) notify(method string, params any) {
+	s.write(map[string]any{"method": method, "params": params})
+}
+
+func (s *lspScript) at(method string, line, character int) int {
+	return s.request(method, map[string]any{
+		"textDocument": map[string]any{"uri": lspURI},
+		"position":     map[string]any{"line": line, "character": character},
+		"context":      map[string]any{"includeDeclaration": true},
+	})
+}
+
+func (s *lspScript) run(t *testing.T) (map[int]lspReply, []lspReply) {
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	var output bytes.Buffer
+	require.NoError(t, gx.NewLanguageServer(&s.input, &output).Serve())
+
+	responses := make(map[int]lspReply)
+	var notifications []lspReply
+	reader := bufio.NewReader(&output)
+	for {
+		header, err := reader.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		length, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "Content-Length:")))
+		require.NoError(t, err)
+		_, err = reader.ReadString('\n')
+		require.NoError(t, err)
+		body := make([]byte, length)
+		_, err = io.ReadFull(reader, body)
+		require.NoError(t, err)
+
+		var reply lspReply
+		require.NoError(t, json.Unmarshal(body, &reply))
+		if reply.ID != nil {
+			responses[*reply.ID] = reply
+		} else {
+			notifications = append(notifications, reply)
+		}
+	}
+	return responses, notifications
+}
+
+const lspSource = `var total = 0;
+fun add(amount, times) {
+  var step = amount * times;
+  total = total + step;
+  return step;
+}
+class Counter < Base {
+  bump() { return add(1, 2); }
+}
+print add(total, 2);
+`
+
+func TestLsp_Initialize(t *testing.T) {
+	responses, _ := newLspScript("").run(t)
+	assert.JSONEq(t, `{
+		"capabilities": {
+			"textDocumentSync": 1,
+			"definitionProvider": true,
+			"referencesProvider": true,
+			"hoverProvider": true,
+			"documentSymbolProvider": true,
+			"completionProvider": {}
+		},
+		"serverInfo": {"name": "golox"}
+	}`, string(responses[1].Result))
+	assert.Equal(t, "null", string(responses[2].Result))
+}
+
+func TestLsp_PublishesDiagnostics(t *testing.T) {
+	script := newLspScript("print 1 +;\n")
+	script.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": lspURI, "version": 2},
+		"contentChanges": []any{map[string]any{"text": "{\n  var a = 1;\n  var a = 2;\n  print a;\n}\n"}},
+	})
+	script.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": lspURI, "version": 3},
+		"contentChanges": []any{map[string]any{"text": "print 1;\n"}},
+	})
+	_, notifications := script.run(t)
+	require.Len(t, notifications, 3)
+
+	assert.Equal(t, "textDocument/publishDiagnostics", notifications[0].Method)
+	assert.JSONEq(t, `{"uri": "`+lspURI+`", "diagnostics": [{
+		"range": {"start": {"line": 0, "character": 9}, "end": {"line": 0, "character": 10}},
+		"severity": 1,
+		"code": "parse-error",
+		"source": "golox",
+		"message": "Expected expression."
+	}]}`, string(notifications[0].Params))
+
+	assert.JSONEq(t, `{"uri": "`+lspURI+`", "diagnostics": [{
+		"range": {"start": {"line": 2, "character": 6}, "end": {"line": 2, "character": 7}},
+		"severity": 1,
+		"code": "resolve-error",
+		"source": "golox",
+		"message": "Already a variable with this name in this scope.",
+		"relatedInformation": [{
+			"location": {"uri": "`+lspURI+`", "range": {"start": {"line": 1, "character": 6}, "end": {"line": 1, "character": 7}}},
+			"message": "'a' was first declared at 2:7."
+		}]
+	}]}`, string(notifications[1].Params))
+
+	assert.JSONEq(t, `{"uri": "`+lspURI+`", "diagnostics": []}`, string(notifications[2].Params))
+}
+
+func TestLsp_Definition(t *testing.T) {
+	script := newLspScript(lspSource)
+	local := script.at("textDocument/definition", 3, 18)    // step in `total + step'
+	global := script.at("textDocument/definition", 9, 11)   // total in `add(total, 2)'
+	function := script.at("textDocument/definition", 7, 20) // add in bump()
+	nothing := script.at("textDocument/definition", 9, 0)   // print
+	responses, _ := script.run(t)
+
+	assert.JSONEq(t, `{"uri": "`+lspURI+`", "range": {"start": {"line": 2, "character": 6}, "end": {"line": 2, "character": 10}}}`, string(responses[local].Result))
+	assert.JSONEq(t, `{"uri": "`+lspURI+`", "range": {"start": {"line": 0, "character": 4}, "end": {"line": 0, "character": 9}}}`, string(responses[global].Result))
+	assert.JSONEq(t, `{"uri": "`+lspURI+`", "range": {"start": {"line": 1, "character": 4}, "end": {"line": 1, "character": 7}}}`, string(responses[function].Result))
+	assert.Equal(t, "null", string(responses[nothing].Result))
+}
+
+func TestLsp_References(t *testing.T) {
+	script := newLspScript(lspSource)
+	id := script.at("textDocument/references", 0, 6)
+	responses, _ := script.run(t)
+
+	var locations []struct {
+		Range struct {
+			Start struct{ Line, Character int }
+		}
+	}
+	require.NoError(t, json.Unmarshal(responses[id].Result, &locations))
+	var starts []string
+	for _, location := range locations {
+		starts = append(starts, fmt.Sprintf("%d:%d", location.Range.Start.Line, location.Range.Start.Character))
+	}
+	assert.Equal(t, []string{"0:4", "3:2", "3:10", "9:10"}, starts)
+}
+
+func TestLsp_Hover(t *testing.T) {
+	script := newLspScript(lspSource)
+	function := script.at("textDocument/hover", 9, 7)
+	parameter := script.at("textDocument/hover", 2, 14)
+	class := script.at("textDocument/hover", 6, 8)
+	method := script.at("textDocument/hover", 7, 3)
+	responses, _ := script.run(t)
+
+	hover := func(id int) string {
+		var result struct{ Contents struct{ Value string } }
+		require.NoError(t, json.Unmarshal(responses[id].Result, &result))
+		return result.Contents.Value
+	}
+	assert.Equal(t, "(function) add(amount, times)", hover(function))
+	assert.Equal(t, "(parameter) amount", hover(parameter))
+	assert.Equal(t, "(class) Counter < Base", hover(class))
+	assert.Equal(t, "(method) bump()", hover(method))
+}
+
+func TestLsp_HoverShowsDocComments(t *testing.T) {
+	script := newLspScript("/// The answer.\n/// Computed slowly.\nvar answer = 42;\nprint answer;\n")
+	hover := script.at("textDocument/hover", 3, 7)
+	responses, _ := script.run(t)
+
+	var result struct{ Contents struct{ Value string } }
+	require.NoError(t, json.Unmarshal(responses[hover].Result, &result))
+	assert.Equal(t, "(variable) answer\n\nThe answer.\nComputed slowly.", result.Contents.Value)
+}
+
+func TestLsp_DocumentSymbols(t *testing.T) {
+	script := newLspScript(lspSource)
+	id := script.request("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": lspURI}})
+	responses, _ := script.run(t)
+
+	type symbol struct {
+		Name     string
+		Kind     int
+		Children []symbol
+	}
+	var symbols []symbol
+	require.NoError(t, json.Unmarshal(responses[id].Result, &symbols))
+	assert.Equal(t, []symbol{
+		{Name: "total", Kind: 13, Children: []symbol{}},
+		{Name: "add", Kind: 12, Children: []symbol{{Name: "step", Kind: 13, Children: []symbol{}}}},
+		{Name: "Counter", Kind: 5, Children: []symbol{{Name: "bump", Kind: 6, Children: []symbol{}}}},
+	}, symbols)
+}
+
+func TestLsp_Completion(t *testing.T) {
+	script := newLspScript(lspSource)
+	inside := script.at("textDocument/completion", 4, 2)
+	outside := script.at("textDocument/completion", 10, 0)
+	responses, _ := script.run(t)
+
+	labels := func(id int) []string {
+		var items []struct{ Label string }
+		require.NoError(t, json.Unmarshal(responses[id].Result, &items))
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Label)
+		}
+		return names
+	}
+	insideLabels := labels(inside)
+	assert.Equal(t, []string{"step", "times", "amount", "total", "add", "Counter"}, insideLabels[:6])
+	assert.Contains(t, insideLabels, "while")
+	assert.Contains(t, insideLabels, "return")
+
+	outsideLabels := labels(outside)
+	assert.Equal(t, []string{"total", "add", "Counter", "and"}, outsideLabels[:4])
+	assert.NotContains(t, outsideLabels, "step")
+}
+
+func TestLsp_Utf16Positions(t *testing.T) {
+	script := newLspScript("var s = \"😀\"; var after = 1;\nprint after;\n")
+	id := script.at("textDocument/definition", 1, 8)
+	responses, _ := script.run(t)
+	// The emoji is one rune but two UTF-16 code units.
+	assert.JSONEq(t, `{"uri": "`+lspURI+`", "range": {"start": {"line": 0, "character": 18}, "end": {"line": 0, "character": 23}}}`, string(responses[id].Result))
+}
+
+func TestLsp_Errors(t *testing.T) {
+	script := newLspScript("")
+	unknown := script.request("workspace/symbol", map[string]any{"query": ""})
+	closed := script.request("textDocument/hover", map[string]any{
+		"textDocument": map[string]any{"uri": "file:///missing.gx"},
+		"position":     map[string]any{"line": 0, "character": 0},
+	})
+	responses, _ := script.run(t)
+	assert.Equal(t, -32601, responses[unknown].Error.Code)
+	assert.Equal(t, -32602, responses[closed].Error.Code)
+
+	var output bytes.Buffer
+	exit := `{"jsonrpc":"2.0","method":"exit"}`
+	err := gx.NewLanguageServer(strings.NewReader(fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(exit), exit)), &output).Serve()
+	assert.EqualError(t, err, "lsp: exit before shutdown")
+}
+
+func TestLsp_PublishesWarnings(t *testing.T) {
+	_, notifications := newLspScript("{\n  var unused = 1;\n}\n").run(t)
+	require.Len(t, notifications, 1)
+	assert.JSONEq(t, `{"uri": "`+lspURI+`", "diagnostics": [{
+		"range": {"start": {"line": 1, "character": 6}, "end": {"line": 1, "character": 12}},
+		"severity": 2,
+		"code": "resolve-warning",
+		"source": "golox",
+		"message": "Local variable is never used."
+	}]}`, string(notifications[0].Params))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	gx "golox/internal"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Each testdata/malformed/<name>.gx file is paired with <name>.expected,
+// listing every diagnostic the scanner and parser should report, one per line.
+func TestParser_ReportsAllSyntaxErrors(t *testing.T) {
+	files, err := filepath.Glob("testdata/malformed/*.gx")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			assert.NoError(t, err)
+			expected, err := os.ReadFile(strings.TrimSuffix(file, ".gx") + ".expected")
+			assert.NoError(t, err)
+
+			diagnostics := parseErrors(string(source))
+			assert.Equal(t, strings.Split(strings.TrimSpace(string(expected)), "\n"), diagnostics)
+		})
+	}
+}
+
+func TestParser_AttachesDocComments(t *testing.T) {
+	statements := parse(t, `/// Counts things.
+///   Indented line.
+var count = 0;
+
+/// Separated by a blank line, so documents nothing.
+
+fun bump() { count = count + 1; } /// Trailing, not a doc comment.
+//// Four slashes make a plain comment.
+class Counter {
+  /// Starts over.
+  reset() {}
+}
+`)
+	require.Len(t, statements, 3)
+	assert.Equal(t, "Counts things.\n  Indented line.", statements[0].(gx.VarDeclare).Doc)
+	assert.Equal(t, "", statements[1].(*gx.FunctionStmt).Doc)
+	assert.Equal(t, "Starts over.", statements[2].(gx.ClassStmt).Methods[0].Doc)
+}
+
+func TestParser_KeepsStatementsAroundErrors(t *testing.T) {
+	scanner := gx.NewScanner([]byte("var a = 1;\nvar = 2;\nprint a;\n"))
+	tokens, _ := scanner.ScanTokens()
+	parser := gx.NewParser(tokens)
+	statements, errs := parser.Parse()
+
+	assert.Len(t, errs, 1)
+	assert.Len(t, statements, 2)
+}
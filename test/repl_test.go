@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	gx "golox/internal"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runRepl(input string) (string, string, *gx.Repl) {
+	var out, errOut bytes.Buffer
+	repl := gx.NewRepl(&out, &errOut)
+	repl.Run(strings.NewReader(input))
+	return out.String(), errOut.String(), repl
+}
+
+func TestRepl_KeepsStateAndPrintsExpressions(t *testing.T) {
+	out, errOut, _ := runRepl("var a = 1;\na + 2;\na = 5\nprint a;\n")
+	assert.Empty(t, errOut)
+	assert.Equal(t, "> > 3\n> 5\n> 5\n> \n", out)
+}
+
+func TestRepl_MultiLineInput(t *testing.T) {
+	out, errOut, _ := runRepl("fun add(a, b) {\n  return a + b;\n}\nadd(\n  1, 2)\n")
+	assert.Empty(t, errOut)
+	assert.Equal(t, "> ... ... > ... 3\n> \n", out)
+}
+
+func TestRepl_MultiLineStrings(t *testing.T) {
+	out, errOut, _ := runRepl("print \"one\ntwo\";\nprint \"a ${\n  1 + 2 }\";\n/* a\n*/ print \"b\";\n")
+	assert.Empty(t, errOut)
+	assert.Equal(t, "> ... one\ntwo\n> ... a 3\n> ... b\n> \n", out)
+}
+
+func TestRepl_RecoversFromErrors(t *testing.T) {
+	out, errOut, _ := runRepl("print missing;\nvar = ;\nprint \"still here\";\n")
+	assert.Equal(t, ""+
+		"<repl>:1:7: error: Undefined variable 'missing'.\n"+
+		"    1 | print missing;\n"+
+		"      |       ^~~~~~~\n"+
+		"<repl>:1:5: error: Expected variable name.\n"+
+		"    1 | var = ;\n"+
+		"      |     ^\n",
+		errOut)
+	assert.Contains(t, out, "still here")
+}
+
+func TestRepl_History(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "history")
+
+	var out bytes.Buffer
+	repl := gx.NewRepl(&out, &out)
+	repl.HistoryFile = historyFile
+	repl.Run(strings.NewReader("var a = 1;\n{\n  print a;\n}\n"))
+
+	restored := gx.NewRepl(&out, &out)
+	restored.HistoryFile = historyFile
+	assert.NoError(t, restored.LoadHistory())
+	assert.Equal(t, []string{"var a = 1;", "{\n  print a;\n}"}, restored.History)
+
+	out.Reset()
+	restored.Run(strings.NewReader(":history\n"))
+	assert.Equal(t, ">    1  var a = 1;\n   2  {\n  print a;\n}\n> \n", out.String())
+}
+
+func TestRepl_Redo(t *testing.T) {
+	out, errOut, repl := runRepl("var a = 1;\na = a + 1;\n:redo\n:redo 1\nprint a;\n:redo 9\n")
+	assert.Equal(t, "No such history entry.\n", errOut)
+	assert.Equal(t, "> > 2\n> a = a + 1;\n3\n> var a = 1;\n> 1\n> > \n", out)
+	assert.Equal(t, []string{"var a = 1;", "a = a + 1;", "a = a + 1;", "var a = 1;", "print a;"}, repl.History)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	gx "golox/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver_ReadInOwnInitializer(t *testing.T) {
+	assert.Equal(t,
+		[]string{"3:11: at 'a': Can't read local variable in its own initializer."},
+		resolveErrors(t, "var a = 1;\n{\n  var a = a + 2;\n  print a;\n}\n"))
+}
+
+func TestResolver_GlobalMayReadItself(t *testing.T) {
+	assert.Empty(t, resolveErrors(t, "var a = 1;\nvar a = a + 1;\n"))
+}
+
+func TestResolver_RedeclareInSameScope(t *testing.T) {
+	assert.Equal(t,
+		[]string{"3:7: at 'a': Already a variable with this name in this scope."},
+		resolveErrors(t, "{\n  var a = 1;\n  var a = 2;\n  print a;\n}\n"))
+}
+
+func TestResolver_UnusedLocals(t *testing.T) {
+	assert.Equal(t,
+		[]string{
+			"2:7: at 'a': Local variable is never used.",
+			"3:7: at 'b': Local variable is never used.",
+		},
+		resolveErrors(t, "{\n  var a = 1;\n  var b;\n  b = 2;\n  var c = 3;\n  print c;\n}\n"))
+}
+
+// Unused locals are only warnings, so the program still runs.
+func TestResolver_UnusedLocalsAreWarnings(t *testing.T) {
+	source := []byte("{\n  var a = 1;\n}\n")
+	errs := gx.Check(source)
+	require.Len(t, errs, 1)
+	assert.IsType(t, &gx.ResolveWarning{}, errs[0])
+	assert.False(t, gx.HasErrors(errs))
+	assert.Equal(t, gx.SeverityWarning, gx.NewDiagnostic(errs[0]).Severity)
+
+	_, err := runProgram(t, string(source))
+	assert.NoError(t, err)
+}
+
+func TestResolver_UnusedParametersAreAllowed(t *testing.T) {
+	assert.Empty(t, resolveErrors(t, "fun ignore(a, b) {\n  return 1;\n}\n"))
+}
+
+func TestResolver_ClosureSeesDeclarationScope(t *testing.T) {
+	out, err := runProgram(t, `
+var a = "global";
+{
+  fun showA() {
+    print a;
+  }
+
+  showA();
+  var a = "block";
+  showA();
+  print a;
+}
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "global\nglobal\nblock\n", out)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	gx "golox/internal"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scan(t *testing.T, source string) []gx.Token {
+	t.Helper()
+	scanner := gx.NewScanner([]byte(source))
+	tokens, errs := scanner.ScanTokens()
+	require.Empty(t, errs)
+	return tokens
+}
+
+func scanErrors(source string) []string {
+	scanner := gx.NewScanner([]byte(source))
+	_, errs := scanner.ScanTokens()
+	messages := make([]string, len(errs))
+	for n, err := range errs {
+		messages[n] = err.Error()
+	}
+	return messages
+}
+
+func TestScanner_EscapeSequences(t *testing.T) {
+	tokens := scan(t, `"a\nb\tc\"d\\e\u{48}\u{1F600}\r\0"`)
+	require.Equal(t, gx.STRING, tokens[0].TokenType)
+	assert.Equal(t, "a\nb\tc\"d\\eH\U0001F600\r\x00", tokens[0].Literal)
+	assert.Equal(t, `a\nb\tc\"d\\e\u{48}\u{1F600}\r\0`, tokens[0].Lexeme)
+	assert.Equal(t, len(`"a\nb\tc\"d\\e\u{48}\u{1F600}\r\0"`), tokens[0].Length)
+}
+
+func TestScanner_BadEscapeSequences(t *testing.T) {
+	assert.Equal(t, []string{"1:9: Unknown escape sequence '\\q'."}, scanErrors(`print "a\q";`))
+	assert.Equal(t, []string{"1:3: Expected '{' and up to six hex digits and '}' after '\\u'."}, scanErrors(`"a\u0041";`))
+	assert.Equal(t, []string{"1:2: Invalid escape sequence '\\u{zz}'."}, scanErrors(`"\u{zz}";`))
+	assert.Equal(t, []string{"1:2: Invalid escape sequence '\\u{}'."}, scanErrors(`"\u{}";`))
+	assert.Equal(t, []string{"1:2: '\\u{110000}' is not a Unicode code point."}, scanErrors(`"\u{110000}";`))
+	assert.Equal(t, []string{"1:2: '\\u{D800}' is not a Unicode code point."}, scanErrors(`"\u{D800}";`))
+	assert.Equal(t, []string{"2:3: Unknown escape sequence '\\x'."}, scanErrors("\"one\n  \\x\";"))
+}
+
+func TestScanner_MultiLineStrings(t *testing.T) {
+	source := "var s = \"one\r\n  two\nthree\";\nprint s;"
+	tokens := scan(t, source)
+
+	str := tokens[3]
+	require.Equal(t, gx.STRING, str.TokenType)
+	assert.Equal(t, "one\n  two\nthree", str.Literal)
+	assert.Equal(t, 1, str.Line)
+	assert.Equal(t, 9, str.Column)
+	assert.Equal(t, gx.Span{
+		Start: gx.Position{Line: 1, Column: 9, Offset: 8},
+		End:   gx.Position{Line: 3, Column: 7, Offset: 26},
+	}, str.Span())
+	assert.Equal(t, "\"one\r\n  two\nthree\"", text(source, str.Span()))
+
+	semicolon := tokens[4]
+	assert.Equal(t, 3, semicolon.Line)
+	assert.Equal(t, 7, semicolon.Column)
+	assert.Equal(t, 4, tokens[5].Line)
+}
+
+func TestScanner_UnterminatedMultiLineString(t *testing.T) {
+	assert.Equal(t, []string{"2:7: Unterminated string."}, scanErrors("var a;\nprint \"one\ntwo;\n"))
+}
+
+func TestScanner_Identifiers(t *testing.T) {
+	tokens := scan(t, "var _count = 1; var café_2 = 2; var 名前 = 3; print ñ;")
+	var names []string
+	for _, token := range tokens {
+		if token.TokenType == gx.IDENTIFIER {
+			names = append(names, token.Lexeme)
+		}
+	}
+	assert.Equal(t, []string{"_count", "café_2", "名前", "ñ"}, names)
+
+	// Columns count bytes, so the `=' after `café_2' is at 29, not 28.
+	assert.Equal(t, 29, tokens[7].Column)
+}
+
+func TestScanner_UnexpectedCharacters(t *testing.T) {
+	assert.Equal(t, []string{"1:9: Unexpected character '€'."}, scanErrors("var a = €;"))
+	assert.Equal(t, []string{"1:9: Invalid UTF-8 encoding."}, scanErrors("var a = \xff;"))
+}
+
+func TestScanner_TabsAndCarriageReturns(t *testing.T) {
+	tokens := scan(t, "var\ta = 1;\r\n\tprint a;\r\n")
+	assert.Len(t, tokens, 9)
+	print := tokens[5]
+	assert.Equal(t, gx.PRINT, print.TokenType)
+	assert.Equal(t, 2, print.Line)
+	assert.Equal(t, 2, print.Column)
+}
+
+func TestScanner_Interpolation(t *testing.T) {
+	source := `"a ${b} c ${ {} } \${d} e"`
+	tokens := scan(t, source)
+	var types []gx.TokenType
+	for _, token := range tokens {
+		types = append(types, token.TokenType)
+	}
+	assert.Equal(t, []gx.TokenType{
+		gx.INTERPOLATION, gx.IDENTIFIER, gx.INTERPOLATION, gx.LEFT_BRACE, gx.RIGHT_BRACE, gx.STRING, gx.EOF,
+	}, types)
+	assert.Equal(t, "a ", tokens[0].Literal)
+	assert.Equal(t, " c ", tokens[2].Literal)
+	assert.Equal(t, " ${d} e", tokens[5].Literal)
+	assert.Equal(t, `"a ${`, text(source, tokens[0].Span()))
+	assert.Equal(t, `} c ${`, text(source, tokens[2].Span()))
+	assert.Equal(t, `} \${d} e"`, text(source, tokens[5].Span()))
+}
+
+func TestScanner_UnterminatedInterpolation(t *testing.T) {
+	assert.Equal(t, []string{"1:10: Unterminated string interpolation."}, scanErrors(`print "a ${b;`))
+	assert.Equal(t, []string{"1:13: Unterminated string."}, scanErrors(`print "a ${b} c;`))
+	assert.Equal(t, []string{"1:14: at 'c': Expected '}' after interpolated expression."}, parseErrors(`print "a ${b c}";`))
+}
+
+// An empty or unterminated `${' is one error, whatever the parser makes of
+// the tokens after it.
+func TestScanner_InterpolationErrorsDoNotCascade(t *testing.T) {
+	assert.Equal(t, []string{"1:9: Expected an expression between '${' and '}'."}, parseErrors(`print "a${}b";`))
+	assert.Equal(t, []string{"1:14: Expected an expression between '${' and '}'."}, parseErrors(`print "a${b}c${ }d";`))
+	assert.Equal(t, []string{"1:8: Unterminated string interpolation."}, parseErrors(`print "${1";`))
+	assert.Equal(t, []string{"1:8: Unterminated string interpolation."}, parseErrors("print \"${1 +\nprint 2;"))
+	assert.Equal(t, []string{"1:8: Unterminated string interpolation."}, parseErrors(`print "${ "${1`))
+
+	scanner := gx.NewScanner([]byte(`"a${}b"`))
+	tokens, _ := scanner.ScanTokens()
+	require.Len(t, tokens, 2)
+	assert.Equal(t, gx.STRING, tokens[0].TokenType)
+	assert.Equal(t, "a${}b", tokens[0].Lexeme)
+}
+
+func TestScanner_BlockComments(t *testing.T) {
+	source := "/* one\n/* two\n*/ still\n*/ print 1; /**/\nprint 2;"
+	scanner := gx.NewScanner([]byte(source))
+	tokens, errs := scanner.ScanTokens()
+	require.Empty(t, errs)
+
+	require.Len(t, scanner.Comments, 2)
+	assert.Equal(t, "/* one\n/* two\n*/ still\n*/", scanner.Comments[0].Lexeme)
+	assert.Equal(t, gx.Position{Line: 4, Column: 3, Offset: 25}, scanner.Comments[0].Span().End)
+	assert.Equal(t, "/**/", scanner.Comments[1].Lexeme)
+
+	assert.Equal(t, gx.PRINT, tokens[0].TokenType)
+	assert.Equal(t, 4, tokens[0].Line)
+	assert.Equal(t, 4, tokens[0].Column)
+	assert.Equal(t, 5, tokens[3].Line)
+}
+
+func TestScanner_UnterminatedBlockComment(t *testing.T) {
+	assert.Equal(t, []string{"2:1: Unterminated block comment."}, scanErrors("print 1;\n/* a /* b */\n"))
+}
+
+func TestScanner_DocComments(t *testing.T) {
+	scanner := gx.NewScanner([]byte("/// doc\n// plain\n//// banner\nvar a;"))
+	tokens, errs := scanner.ScanTokens()
+	require.Empty(t, errs)
+	assert.Equal(t, gx.DOC_COMMENT, tokens[0].TokenType)
+	assert.Equal(t, "/// doc", tokens[0].Lexeme)
+	assert.Equal(t, gx.VAR, tokens[1].TokenType)
+	assert.Len(t, scanner.Comments, 3)
+}
+
+func TestScanner_Shebang(t *testing.T) {
+	scanner := gx.NewScanner([]byte("#!/usr/bin/env golox\nprint 1;\n"))
+	tokens, errs := scanner.ScanTokens()
+	require.Empty(t, errs)
+	assert.Equal(t, gx.PRINT, tokens[0].TokenType)
+	assert.Equal(t, 2, tokens[0].Line)
+	require.Len(t, scanner.Comments, 1)
+	assert.Equal(t, "#!/usr/bin/env golox", scanner.Comments[0].Lexeme)
+	assert.Equal(t, 1, scanner.Comments[0].Line)
+
+	// Only the first line may be one.
+	assert.Equal(t, []string{"2:1: Unexpected character '#'."}, scanErrors("print 1;\n#!/usr/bin/env golox\n"))
+}
+
+func TestScanner_Numbers(t *testing.T) {
+	tests := map[string]any{
+		"0xFF":       int64(255),
+		"0Xff_ff":    int64(65535),
+		"0b1010":     int64(10),
+		"1e-9":       1e-9,
+		"1.5E+3":     1500.0,
+		"2e3":        2000.0,
+		"1_000_000":  int64(1000000),
+		"3.141_592":  3.141592,
+		"0":          int64(0),
+		"12.5":       12.5,
+		"0x7fff_fff": int64(0x7ffffff),
+	}
+	for source, value := range tests {
+		tokens := scan(t, source)
+		require.Len(t, tokens, 2, source)
+		assert.Equal(t, gx.NUMBER, tokens[0].TokenType, source)
+		assert.Equal(t, source, tokens[0].Lexeme)
+		assert.Equal(t, value, tokens[0].Literal, source)
+	}
+}
+
+func TestScanner_NumbersAtEndOfInput(t *testing.T) {
+	for _, source := range []string{"1", "1.", "1e", "1e-", "0x", "0b", "1_", "."} {
+		scanner := gx.NewScanner([]byte(source))
+		tokens, _ := scanner.ScanTokens()
+		assert.Equal(t, gx.EOF, tokens[len(tokens)-1].TokenType, source)
+	}
+	tokens := scan(t, "1.")
+	assert.Equal(t, []gx.TokenType{gx.NUMBER, gx.DOT, gx.EOF}, []gx.TokenType{tokens[0].TokenType, tokens[1].TokenType, tokens[2].TokenType})
+}
+
+// FuzzScanner checks that the scanner never panics and that every token,
+// error included, lies inside the source where its line and column say.
+func FuzzScanner(f *testing.F) {
+	examples, _ := filepath.Glob("../example/*.gx")
+	for _, example := range examples {
+		source, err := os.ReadFile(example)
+		require.NoError(f, err)
+		f.Add(string(source))
+	}
+	f.Add("\"a\\u{1F600}\\n\r\nb\"")
+	f.Add("var café = \"\\q\";\t\xff")
+	f.Add("\"a ${ \"b ${c}\" + {} }\" ${")
+	f.Add("0xFF 0b10 1e-9 1_000 .5 1e 0x_ 3px")
+	f.Add("#!golox\n/// doc\n/* a /* b\n*/ c */ 1 /* d")
+
+	f.Fuzz(func(t *testing.T, source string) {
+		scanner := gx.NewScanner([]byte(source))
+		tokens, errs := scanner.ScanTokens()
+		require.NotEmpty(t, tokens)
+		require.Equal(t, gx.EOF, tokens[len(tokens)-1].TokenType)
+
+		check := func(token gx.Token) {
+			require.GreaterOrEqual(t, token.Offset, 0)
+			require.GreaterOrEqual(t, token.Length, 0)
+			require.LessOrEqual(t, token.Offset+token.Length, len(source))
+
+			lineStart := strings.LastIndexByte(source[:token.Offset], '\n') + 1
+			require.Equal(t, strings.Count(source[:token.Offset], "\n")+1, token.Line, "line of %v", token)
+			require.Equal(t, token.Offset-lineStart+1, token.Column, "column of %v", token)
+		}
+
+		offset := 0
+		for _, token := range tokens {
+			check(token)
+			require.GreaterOrEqual(t, token.Offset, offset, "tokens out of order")
+			offset = token.Offset + token.Length
+			if token.TokenType == gx.STRING {
+				require.True(t, utf8.ValidString(token.Literal.(string)) || !utf8.ValidString(source))
+			}
+		}
+		for _, err := range errs {
+			check(err.(*gx.ScanError).Token)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	gx "golox/internal"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToken_RecordsPosition(t *testing.T) {
+	scanner := gx.NewScanner([]byte("var s = \"hi\";\n  print s;\n"))
+	tokens, errs := scanner.ScanTokens()
+	require.Empty(t, errs)
+
+	str := tokens[3]
+	assert.Equal(t, gx.STRING, str.TokenType)
+	assert.Equal(t, 1, str.Line)
+	assert.Equal(t, 9, str.Column)
+	assert.Equal(t, 8, str.Offset)
+	assert.Equal(t, 4, str.Length)
+
+	print := tokens[5]
+	assert.Equal(t, gx.PRINT, print.TokenType)
+	assert.Equal(t, gx.Span{
+		Start: gx.Position{Line: 2, Column: 3, Offset: 16},
+		End:   gx.Position{Line: 2, Column: 8, Offset: 21},
+	}, print.Span())
+}
+
+func TestToken_LineNumbersPast65535(t *testing.T) {
+	source := strings.Repeat("\n", 70000) + "x;"
+	scanner := gx.NewScanner([]byte(source))
+	tokens, _ := scanner.ScanTokens()
+	assert.Equal(t, 70001, tokens[0].Line)
+}
+
+// text returns the source a span covers.
+func text(source string, span gx.Span) string {
+	return source[span.Start.Offset:span.End.Offset]
+}
+
+func TestSpan_CoversWholeNodes(t *testing.T) {
+	source := `var total = (1 + 2) * add(3, 4);
+if (total > 0) {
+  print total;
+} else print -total;
+for (var i = 0; i < 3; i = i + 1) print i;
+class A < B {
+  m() { return this.x.y = super.z; }
+}
+`
+	statements := parse(t, source)
+	require.Len(t, statements, 4)
+
+	varDecl := statements[0].(gx.VarDeclare)
+	assert.Equal(t, "var total = (1 + 2) * add(3, 4);", text(source, varDecl.Span()))
+	assert.Equal(t, "(1 + 2) * add(3, 4)", text(source, varDecl.InitialExpr.Span()))
+	call := varDecl.InitialExpr.(*gx.Binary).Right
+	assert.Equal(t, "add(3, 4)", text(source, call.Span()))
+
+	ifStmt := statements[1].(gx.IfStmt)
+	assert.Equal(t, "if (total > 0) {\n  print total;\n} else print -total;", text(source, ifStmt.Span()))
+	assert.Equal(t, "print -total;", text(source, ifStmt.ElseBranch.Span()))
+	assert.Equal(t, "-total", text(source, ifStmt.ElseBranch.(gx.Print).Expr.Span()))
+
+	forLoop := statements[2]
+	assert.Equal(t, "for (var i = 0; i < 3; i = i + 1) print i;", text(source, forLoop.Span()))
+
+	class := statements[3].(gx.ClassStmt)
+	assert.Equal(t, "class A < B {\n  m() { return this.x.y = super.z; }\n}", text(source, class.Span()))
+	method := class.Methods[0]
+	assert.Equal(t, "m() { return this.x.y = super.z; }", text(source, method.Span()))
+	ret := method.Body[0].(gx.ReturnStmt)
+	assert.Equal(t, "return this.x.y = super.z;", text(source, ret.Span()))
+	assert.Equal(t, "this.x.y = super.z", text(source, ret.Value.Span()))
+	assert.Equal(t, "super.z", text(source, ret.Value.(*gx.Set).Value.Span()))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	gx "golox/internal"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValue_String(t *testing.T) {
+	tests := []struct {
+		value    gx.Value
+		expected string
+	}{
+		{gx.NilValue(), "nil"},
+		{gx.BoolValue(true), "true"},
+		{gx.BoolValue(false), "false"},
+		{gx.IntValue(3), "3"},
+		{gx.IntValue(math.MinInt64), "-9223372036854775808"},
+		{gx.FloatValue(3), "3.0"},
+		{gx.FloatValue(-2.5), "-2.5"},
+		{gx.FloatValue(123456789), "123456789.0"},
+		{gx.FloatValue(1e16), "1e+16"},
+		{gx.FloatValue(1e21), "1e+21"},
+		{gx.FloatValue(0.1), "0.1"},
+		{gx.FloatValue(math.Copysign(0, -1)), "-0.0"},
+		{gx.FloatValue(math.Inf(1)), "Infinity"},
+		{gx.FloatValue(math.NaN()), "NaN"},
+		{gx.StringValue("text"), "text"},
+		{gx.ObjectValue(&gx.GlobalClock{}), "<native fn>"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.value.String())
+	}
+}
+
+func TestValue_Predicates(t *testing.T) {
+	assert.True(t, gx.NilValue().IsNil())
+	assert.True(t, gx.BoolValue(false).IsBool())
+	assert.True(t, gx.IntValue(0).IsNumber())
+	assert.True(t, gx.IntValue(0).IsInt())
+	assert.True(t, gx.FloatValue(0).IsNumber())
+	assert.True(t, gx.FloatValue(0).IsFloat())
+	assert.False(t, gx.FloatValue(0).IsInt())
+	assert.True(t, gx.StringValue("").IsString())
+	assert.True(t, gx.ObjectValue(&gx.GlobalClock{}).IsObject())
+	assert.Equal(t, gx.FloatKind, gx.LiteralValue(1.0).Kind())
+	assert.Equal(t, gx.IntKind, gx.LiteralValue(int64(1)).Kind())
+	assert.Equal(t, gx.StringKind, gx.LiteralValue("1").Kind())
+
+	assert.False(t, gx.NilValue().Truthy())
+	assert.False(t, gx.BoolValue(false).Truthy())
+	assert.True(t, gx.IntValue(0).Truthy())
+	assert.True(t, gx.FloatValue(0).Truthy())
+	assert.True(t, gx.StringValue("").Truthy())
+
+	assert.Equal(t, 0.0, gx.StringValue("1").AsFloat())
+	assert.Equal(t, 2.0, gx.IntValue(2).AsFloat())
+	assert.Equal(t, int64(0), gx.FloatValue(2).AsInt())
+	assert.Equal(t, "", gx.FloatValue(1).AsString())
+}
+
+func TestValue_Equal(t *testing.T) {
+	clock := &gx.GlobalClock{}
+	assert.True(t, gx.NilValue().Equal(gx.NilValue()))
+	assert.True(t, gx.FloatValue(1).Equal(gx.FloatValue(1)))
+	assert.True(t, gx.IntValue(1).Equal(gx.IntValue(1)))
+	assert.True(t, gx.IntValue(1).Equal(gx.FloatValue(1)))
+	assert.True(t, gx.FloatValue(-4).Equal(gx.IntValue(-4)))
+	assert.True(t, gx.StringValue("a").Equal(gx.StringValue("a")))
+	assert.True(t, gx.ObjectValue(clock).Equal(gx.ObjectValue(clock)))
+
+	assert.False(t, gx.NilValue().Equal(gx.BoolValue(false)))
+	assert.False(t, gx.FloatValue(1).Equal(gx.BoolValue(true)))
+	assert.False(t, gx.IntValue(1).Equal(gx.StringValue("1")))
+	assert.False(t, gx.IntValue(1).Equal(gx.FloatValue(1.5)))
+	assert.False(t, gx.FloatValue(math.NaN()).Equal(gx.FloatValue(math.NaN())))
+	// 2^53 + 1 has no float64, so no float equals it.
+	assert.False(t, gx.IntValue(1<<53+1).Equal(gx.FloatValue(1<<53)))
+	assert.False(t, gx.IntValue(math.MaxInt64).Equal(gx.FloatValue(math.MaxInt64)))
+}